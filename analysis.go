@@ -0,0 +1,466 @@
+package rcswitch
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// Receivers shift edges by some µs, a pulse length is a multiple of the
+	// base pulse length if it is off by at most multipleTolerance of it plus pulseJitter.
+	multipleTolerance = 0.15
+	pulseJitter       = 30 * time.Microsecond
+	// Sorted pulse lengths belong to the same cluster unless one is longer than
+	// clusterSpread times the previous one plus pulseJitter.
+	clusterSpread = 1.1
+	// The base pulse length is searched down to the shortest cluster divided by this.
+	maxDivisor = 16
+	// Receivers tend to lengthen high periods and shorten low ones by the same
+	// amount. To estimate that skew, the shortest high and low period are
+	// taken as multiples of the pulse length up to this.
+	maxSkewMultiple = 4
+	// A larger skew can't be told from a different protocol, e.g. protocol 3
+	// skewed by 100µs looks like protocol 5.
+	maxSkew = 75 * time.Microsecond
+	// Frames needed for an analysis, the separating gap has to be seen repeatedly.
+	analysisFrames = 2
+)
+
+// A PulseCluster is a group of similar pulse lengths of a Recording.
+type PulseCluster struct {
+	Length time.Duration // mean length of the pulses
+	Count  int
+}
+
+// The result of Analyze.
+type Analysis struct {
+	// The suggested protocol. If the signal matches a protocol supported by
+	// SetProtocol, it is returned with Number set and the measured pulse
+	// length, otherwise Number is 0.
+	Protocol ProtocolInfo
+	Clusters []PulseCluster
+	Frames   int // number of frames the protocol was derived from
+	// How much longer high periods are than multiples of the pulse length, low
+	// periods are shorter by as much. Clusters are not corrected for it.
+	Skew time.Duration
+	// Binary code word of each of these frames as sent on the air, bits that
+	// are neither zero nor one are '?'.
+	Codes []string
+}
+
+// Create a Recording from the times of the level changes of a receiver module,
+// e.g., exported from a logic analyzer. The first edge has to be a rising one.
+func RecordingFromEdges(edges []time.Time) Recording {
+	var rec Recording
+	for i := 1; i < len(edges); i++ {
+		rec.Pulses = append(rec.Pulses, edges[i].Sub(edges[i-1]))
+	}
+	return rec
+}
+
+// Split the recording into frames at low periods of at least gap. Every frame
+// starts with a high period and, except for the last one, ends with a low
+// period of at least gap.
+func (rec Recording) Frames(gap time.Duration) []Recording {
+	var frames []Recording
+	start := 0
+	for i := 1; i < len(rec.Pulses); i += 2 {
+		if rec.Pulses[i] >= gap {
+			frames = append(frames, Recording{Pulses: rec.Pulses[start : i+1]})
+			start = i + 1
+		}
+	}
+	if start < len(rec.Pulses) {
+		frames = append(frames, Recording{Pulses: rec.Pulses[start:]})
+	}
+	return frames
+}
+
+// Group the pulse lengths of the recording, ignoring glitches. The clusters
+// are sorted by length.
+func (rec Recording) Clusters() []PulseCluster {
+	pulses := make([]time.Duration, 0, len(rec.Pulses))
+	for _, d := range rec.Pulses {
+		if d >= glitchLimit {
+			pulses = append(pulses, d)
+		}
+	}
+	sort.Slice(pulses, func(i, j int) bool { return pulses[i] < pulses[j] })
+
+	var clusters []PulseCluster
+	var prev, sum time.Duration
+	for _, d := range pulses {
+		if len(clusters) == 0 || float64(d) > float64(prev)*clusterSpread+float64(pulseJitter) {
+			clusters = append(clusters, PulseCluster{})
+			sum = 0
+		}
+		prev = d
+		c := &clusters[len(clusters)-1]
+		sum += d
+		c.Count++
+		c.Length = sum / time.Duration(c.Count)
+	}
+	return clusters
+}
+
+// Reverse engineer the protocol of an unknown remote from a recording of a few
+// repeats of its code, see Record:
+// the recording is split into frames at the long low period of the sync bit,
+// the pulse lengths are clustered to find the base pulse length, and the most
+// common waveforms become the zero and one bits. The zero bit is the one with
+// the shorter high period, so the codes might be inverted for an unknown
+// protocol. Noise before and after the code is skipped, as it does not form
+// frames of the common length.
+func (rec Recording) Analyze() (Analysis, error) {
+	a := Analysis{Clusters: rec.Clusters()}
+	if len(a.Clusters) < 2 {
+		return a, errors.New("Recording does not contain different pulse lengths")
+	}
+
+	// The frames are separated by the longest pulse seen repeatedly, allow for
+	// some jitter of it.
+	var gap time.Duration
+	for _, c := range a.Clusters {
+		if c.Count >= analysisFrames {
+			gap = c.Length * 7 / 10
+		}
+	}
+	if gap < 3*a.Clusters[0].Length {
+		return a, errors.New("Recording does not contain gaps between frames, record more repeats")
+	}
+
+	frames := completeFrames(rec.Frames(gap), gap)
+	if len(frames) < analysisFrames {
+		return a, errors.New("Recording does not contain repeated frames, record more repeats")
+	}
+	a.Frames = len(frames)
+
+	base, skew, err := basePulseLength(frames, gap)
+	if err != nil {
+		return a, err
+	}
+	a.Skew = skew
+	frames = unskew(frames, skew)
+	var sum time.Duration
+	var n int
+	for _, f := range frames {
+		for _, d := range f.Pulses {
+			if d < gap {
+				sum += d
+				n += multiple(d, base)
+			}
+		}
+	}
+	if n > 0 {
+		base = sum / time.Duration(n)
+	}
+
+	// A frame is "data... sync", where sync ends with the gap. On the air,
+	// inverted protocols start with the low period of the sync bit, which is
+	// the gap then, and the frames are "sync data...". Both alignments are
+	// tried, a known protocol wins over more bits that are zero or one.
+	var w waveforms
+	found := false
+	for _, inverted := range []bool{false, true} {
+		c := frameWaveforms(frames, base, inverted)
+		prot, ok := c.protocol(base)
+		if !ok {
+			continue
+		}
+		known, bestKnown := prot.Number != 0, a.Protocol.Number != 0
+		if !found || (known && !bestKnown) || (known == bestKnown && c.score() > w.score()) {
+			w, a.Protocol, found = c, prot, true
+		}
+	}
+	if !found {
+		return a, errors.New("All bits of the recording have the same value, zero and one can not be told apart")
+	}
+
+	for _, bits := range w.data {
+		code := make([]byte, len(bits))
+		for i, b := range bits {
+			switch b {
+			case a.Protocol.Zero:
+				code[i] = '0'
+			case a.Protocol.One:
+				code[i] = '1'
+			default:
+				code[i] = '?'
+			}
+		}
+		a.Codes = append(a.Codes, string(code))
+	}
+	return a, nil
+}
+
+// Returns the frames ending with the gap that have the most common length.
+func completeFrames(frames []Recording, gap time.Duration) []Recording {
+	count := make(map[int]int)
+	common := 0
+	for _, f := range frames {
+		n := len(f.Pulses)
+		if n < 4 || f.Pulses[n-1] < gap {
+			continue
+		}
+		count[n]++
+		if count[n] > count[common] {
+			common = n
+		}
+	}
+
+	var complete []Recording
+	for _, f := range frames {
+		if len(f.Pulses) == common && f.Pulses[common-1] >= gap {
+			complete = append(complete, f)
+		}
+	}
+	return complete
+}
+
+// Returns the pulse length and the skew of high and low periods of frames:
+// corrected by the skew, all clusters shorter than gap that occur at least once
+// per frame are multiples of the pulse length. The skew is 0 or one of those
+// from frameSkews, and together with the pulse length it is the one the pulses
+// deviate least from. On a tie the longer pulse length and then the smaller
+// skew wins.
+func basePulseLength(frames []Recording, gap time.Duration) (time.Duration, time.Duration, error) {
+	var base, skew time.Duration
+	best := math.Inf(1)
+	for _, sk := range append([]time.Duration{0}, frameSkews(frames, gap)...) {
+		var rec Recording
+		for _, f := range unskew(frames, sk) {
+			for _, d := range f.Pulses {
+				if d < gap {
+					rec.Pulses = append(rec.Pulses, d)
+				}
+			}
+		}
+		var clusters []PulseCluster
+		for _, c := range rec.Clusters() {
+			if c.Count >= len(frames) {
+				clusters = append(clusters, c)
+			}
+		}
+		if len(clusters) == 0 {
+			return 0, 0, errors.New("Recording does not contain bits")
+		}
+
+		for div := 1; div <= maxDivisor; div++ {
+			b := clusters[0].Length / time.Duration(div)
+			if !fits(clusters, b) {
+				continue
+			}
+			dev := deviation(rec, b)
+			if dev < best || dev == best && (b > base || b == base && diff(sk, 0) < diff(skew, 0)) {
+				base, skew, best = b, sk, dev
+			}
+		}
+	}
+	if base == 0 {
+		return 0, 0, errors.New("Pulse lengths of the recording are not multiples of a common pulse length")
+	}
+	return base, skew, nil
+}
+
+// Whether all clusters are multiples of base.
+func fits(clusters []PulseCluster, base time.Duration) bool {
+	for _, c := range clusters {
+		off := float64(c.Length) - float64(multiple(c.Length, base))*float64(base)
+		if math.Abs(off) > multipleTolerance*float64(base)+float64(pulseJitter) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the mean squared deviation of the pulses of rec from multiples of
+// base, relative to base.
+func deviation(rec Recording, base time.Duration) float64 {
+	var sum float64
+	for _, d := range rec.Pulses {
+		off := float64(d-time.Duration(multiple(d, base))*base) / float64(base)
+		sum += off * off
+	}
+	return sum / float64(len(rec.Pulses))
+}
+
+// Returns candidates for how much longer the high periods of frames are than
+// multiples of the pulse length. They are derived from the shortest high and
+// low period that occur at least once per frame, for each pair of multiples of
+// the pulse length they might be.
+func frameSkews(frames []Recording, gap time.Duration) []time.Duration {
+	var high, low Recording
+	for _, f := range frames {
+		for i, d := range f.Pulses {
+			switch {
+			case i%2 == 0: // frames start with a high period
+				high.Pulses = append(high.Pulses, d)
+			case d < gap:
+				low.Pulses = append(low.Pulses, d)
+			}
+		}
+	}
+	shortest := func(rec Recording) time.Duration {
+		for _, c := range rec.Clusters() {
+			if c.Count >= len(frames) {
+				return c.Length
+			}
+		}
+		return 0
+	}
+	h, l := shortest(high), shortest(low)
+	if h == 0 || l == 0 {
+		return nil
+	}
+	var skews []time.Duration
+	for m := 1; m <= maxSkewMultiple; m++ {
+		for n := 1; n <= maxSkewMultiple; n++ {
+			// (h-skew)/m == (l+skew)/n
+			sk := (time.Duration(n)*h - time.Duration(m)*l) / time.Duration(m+n)
+			if sk != 0 && diff(sk, 0) <= maxSkew {
+				skews = append(skews, sk)
+			}
+		}
+	}
+	return skews
+}
+
+// Returns frames with the high periods shortened by skew and the low periods
+// lengthened by it.
+func unskew(frames []Recording, skew time.Duration) []Recording {
+	if skew == 0 {
+		return frames
+	}
+	corrected := make([]Recording, len(frames))
+	for i, f := range frames {
+		pulses := make([]time.Duration, len(f.Pulses))
+		for j, d := range f.Pulses {
+			if j%2 == 0 { // frames start with a high period
+				pulses[j] = d - skew
+			} else {
+				pulses[j] = d + skew
+			}
+		}
+		corrected[i] = Recording{Pulses: pulses}
+	}
+	return corrected
+}
+
+// Returns d in multiples of base, at least 1.
+func multiple(d, base time.Duration) int {
+	m := int((d + base/2) / base)
+	if m < 1 {
+		return 1
+	}
+	return m
+}
+
+// Whether sync waveform w, derived from a measured gap, is about v.
+func near(v, w Waveform) bool {
+	within := func(a, b int) bool {
+		return math.Abs(float64(a-b)) <= 1+float64(a)/10
+	}
+	return within(v.High, w.High) && within(v.Low, w.Low)
+}
+
+// The waveforms of frames for one alignment of high and low periods.
+type waveforms struct {
+	inverted bool
+	sync     Waveform
+	data     [][]Waveform // per frame
+	count    map[Waveform]int
+}
+
+func frameWaveforms(frames []Recording, base time.Duration, inverted bool) waveforms {
+	w := waveforms{inverted: inverted, count: make(map[Waveform]int)}
+	var syncHigh, syncLow time.Duration
+	for _, f := range frames {
+		p := f.Pulses
+		n := len(p)
+		var bits []Waveform
+		if inverted {
+			syncHigh += p[n-1]
+			syncLow += p[0]
+			p = p[1 : n-1]
+		} else {
+			syncHigh += p[n-2]
+			syncLow += p[n-1]
+			p = p[:n-2]
+		}
+		for i := 0; i+1 < len(p); i += 2 {
+			b := Waveform{High: multiple(p[i], base), Low: multiple(p[i+1], base)}
+			bits = append(bits, b)
+			w.count[b]++
+		}
+		w.data = append(w.data, bits)
+	}
+	frames64 := time.Duration(len(frames))
+	w.sync = Waveform{High: multiple(syncHigh/frames64, base), Low: multiple(syncLow/frames64, base)}
+	return w
+}
+
+// Returns the two most common waveforms, the zero bit first.
+func (w waveforms) bits() (zero, one Waveform, ok bool) {
+	var first, second Waveform
+	for b, n := range w.count {
+		switch {
+		case n > w.count[first]:
+			first, second = b, first
+		case n > w.count[second]:
+			second = b
+		}
+	}
+	if w.count[second] == 0 {
+		return Waveform{}, Waveform{}, false
+	}
+	if second.High < first.High || (second.High == first.High && second.Low > first.Low) {
+		first, second = second, first
+	}
+	return first, second, true
+}
+
+// Returns the protocol of the waveforms with the given pulse length, the
+// closest supported one if it matches. False if there are no two different bits.
+func (w waveforms) protocol(base time.Duration) (ProtocolInfo, bool) {
+	zero, one, ok := w.bits()
+	if !ok {
+		return ProtocolInfo{}, false
+	}
+
+	prot := ProtocolInfo{
+		PulseLength: base,
+		Sync:        w.sync,
+		Zero:        zero,
+		One:         one,
+		Inverted:    w.inverted,
+		SyncRepeat:  1,
+		Repeat:      10,
+	}
+	best := math.Inf(1)
+	for _, p := range Protocols() {
+		off := math.Abs(float64(p.PulseLength-base)) / float64(p.PulseLength)
+		if p.Inverted != w.inverted || !near(p.Sync, w.sync) || off > multipleTolerance || off >= best {
+			continue
+		}
+		if (p.Zero == zero && p.One == one) || (p.Zero == one && p.One == zero) {
+			p.PulseLength = base
+			prot, best = p, off
+		}
+	}
+	return prot, true
+}
+
+// The share of bits that are one of the two most common waveforms.
+func (w waveforms) score() float64 {
+	zero, one, _ := w.bits()
+	total := 0
+	for _, n := range w.count {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(w.count[zero]+w.count[one]) / float64(total)
+}
@@ -0,0 +1,142 @@
+package rcswitch
+
+import (
+	"testing"
+	"time"
+)
+
+// Returns a recording of tr as a receiver would see it: it starts with the
+// first high period, rising edges are early by jitter and falling edges are
+// late by as much.
+func receive(tr Transmission, jitter time.Duration) Recording {
+	var edges []time.Time
+	t := time.Unix(0, 0)
+	for i, p := range tr.periods() {
+		if len(edges) == 0 && !p.high {
+			continue
+		}
+		shift := jitter
+		if i%2 == 0 {
+			shift = -jitter
+		}
+		edges = append(edges, t.Add(shift))
+		t = t.Add(p.d)
+	}
+	return RecordingFromEdges(append(edges, t))
+}
+
+func TestAnalyze(t *testing.T) {
+	// Protocol 9 is protocol 8 inverted, on the air a frame of protocol 9
+	// ending with a one is a frame of protocol 8, too.
+	const binary = "011010011100101011010010"
+	for _, p := range Protocols() {
+		for _, jitter := range []time.Duration{0, 20 * time.Microsecond, -30 * time.Microsecond} {
+			// The shortest high and low periods of protocols 8 and 9 are
+			// not the same multiple of the pulse length, there is nothing
+			// to tell a skew from.
+			if jitter != 0 && (p.Number == 8 || p.Number == 9) {
+				continue
+			}
+			var frame []Waveform
+			for _, b := range binary {
+				if b == '1' {
+					frame = append(frame, p.One)
+				} else {
+					frame = append(frame, p.Zero)
+				}
+			}
+			frame = append(frame, p.Sync)
+			tr := Transmission{Frame: frame, PulseLength: p.PulseLength, Inverted: p.Inverted, Repeat: 4}
+
+			a, err := receive(tr, jitter).Analyze()
+			if err != nil {
+				t.Errorf("Protocol %d, jitter %v: Analyze failed: %v", p.Number, jitter, err)
+				continue
+			}
+			if a.Protocol.Number != p.Number {
+				t.Errorf("Protocol %d, jitter %v: found protocol %d", p.Number, jitter, a.Protocol.Number)
+			}
+			if off := a.Protocol.PulseLength - p.PulseLength; off > p.PulseLength/20 || off < -p.PulseLength/20 {
+				t.Errorf("Protocol %d, jitter %v: pulse length %v, expected about %v", p.Number, jitter, a.Protocol.PulseLength, p.PulseLength)
+			}
+			if a.Frames < analysisFrames {
+				t.Errorf("Protocol %d, jitter %v: analyzed %d frames", p.Number, jitter, a.Frames)
+			}
+			for _, code := range a.Codes {
+				if code != binary {
+					t.Errorf("Protocol %d, jitter %v: code %s, expected %s", p.Number, jitter, code, binary)
+				}
+			}
+		}
+	}
+}
+
+func TestAnalyzeUnknownProtocol(t *testing.T) {
+	// 500µs pulses with a sync none of the protocols uses
+	prot := ProtocolInfo{PulseLength: 500 * time.Microsecond, Sync: Waveform{High: 2, Low: 20},
+		Zero: Waveform{High: 1, Low: 3}, One: Waveform{High: 3, Low: 1}}
+	var frame []Waveform
+	for _, b := range "1100" {
+		if b == '1' {
+			frame = append(frame, prot.One)
+		} else {
+			frame = append(frame, prot.Zero)
+		}
+	}
+	frame = append(frame, prot.Sync)
+	a, err := receive(Transmission{Frame: frame, PulseLength: prot.PulseLength, Repeat: 3}, 0).Analyze()
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if a.Protocol.Number != 0 || a.Protocol.Sync != prot.Sync || a.Protocol.Zero != prot.Zero || a.Protocol.One != prot.One {
+		t.Errorf("Analyze found %+v, expected %+v", a.Protocol, prot)
+	}
+}
+
+func TestAnalyzeErrors(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name   string
+		pulses []time.Duration
+	}{
+		{"empty", nil},
+		{"single length", []time.Duration{ms, ms, ms, ms}},
+		{"no gaps", []time.Duration{ms, 3 * ms, 3 * ms, ms, ms, 3 * ms}},
+		{"single frame", []time.Duration{ms, 3 * ms, 3 * ms, ms, ms, 31 * ms}},
+	}
+	for _, tt := range tests {
+		if _, err := (Recording{Pulses: tt.pulses}).Analyze(); err == nil {
+			t.Errorf("%s: Analyze does not fail", tt.name)
+		}
+	}
+}
+
+func TestFrames(t *testing.T) {
+	ms := time.Millisecond
+	rec := Recording{Pulses: []time.Duration{ms, 3 * ms, ms, 31 * ms, 3 * ms, ms, ms, 31 * ms, ms}}
+	frames := rec.Frames(10 * ms)
+	want := []int{4, 4, 1}
+	if len(frames) != len(want) {
+		t.Fatalf("Frames() = %v, expected %d frames", frames, len(want))
+	}
+	for i, f := range frames {
+		if len(f.Pulses) != want[i] {
+			t.Errorf("Frame %d has %d pulses, expected %d", i, len(f.Pulses), want[i])
+		}
+	}
+}
+
+func TestClusters(t *testing.T) {
+	us := time.Microsecond
+	rec := Recording{Pulses: []time.Duration{340 * us, 360 * us, 1040 * us, 1060 * us, 350 * us, 10850 * us, 10 * us}}
+	want := []PulseCluster{{350 * us, 3}, {1050 * us, 2}, {10850 * us, 1}}
+	got := rec.Clusters()
+	if len(got) != len(want) {
+		t.Fatalf("Clusters() = %v, expected %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Clusters()[%d] = %v, expected %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package rcswitch
+
+import (
+	"context"
+	"io"
+)
+
+// PinArbiter shares a single Transmitter between multiple RCSwitch objects,
+// e.g., to send different protocols on the same radio. Transmissions are
+// serialized, so they do not corrupt each other.
+//
+//	arbiter := rcswitch.NewPinArbiter(rcswitch.NewGPIOTransmitter(pin))
+//	a, b := rcswitch.NewRCSwitch(pin), rcswitch.NewRCSwitch(pin)
+//	a.SetTransmitter(arbiter)
+//	b.SetTransmitter(arbiter)
+//	b.SetProtocol(2)
+//
+// The shared Transmitter is not closed by RCSwitch.Close, use Halt of the
+// arbiter once all RCSwitch objects are closed.
+type PinArbiter struct {
+	tx   Transmitter
+	turn chan struct{} // holds a token while a transmission is on the air
+}
+
+// Create a PinArbiter for tx.
+func NewPinArbiter(tx Transmitter) *PinArbiter {
+	return &PinArbiter{tx: tx, turn: make(chan struct{}, 1)}
+}
+
+// Transmit implements Transmitter. It waits until other transmissions are
+// done, waiting is aborted when ctx is done.
+func (a *PinArbiter) Transmit(ctx context.Context, t Transmission) error {
+	select {
+	case a.turn <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-a.turn }()
+	return a.tx.Transmit(ctx, t)
+}
+
+// Halt waits for the transmission on the air and closes the shared
+// Transmitter if it implements io.Closer. A GPIO transmitter is set idle.
+func (a *PinArbiter) Halt() error {
+	a.turn <- struct{}{}
+	defer func() { <-a.turn }()
+	switch t := a.tx.(type) {
+	case *gpioTransmitter:
+		return t.pin.Out(t.idle())
+	case io.Closer:
+		return t.Close()
+	}
+	return nil
+}
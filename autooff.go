@@ -0,0 +1,141 @@
+package rcswitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// A pending "off" command of SwitchOnFor.
+type autoOff struct {
+	cmd    Command
+	at     time.Time
+	cancel func()
+}
+
+// Entry of the auto-off file, see SetAutoOffFile.
+type autoOffRecord struct {
+	At        time.Time `json:"at"`
+	Family    string    `json:"family,omitempty"`
+	Group     string    `json:"group"`
+	Device    string    `json:"device"`
+	Protocol  int       `json:"protocol,omitempty"`
+	Repeat    int       `json:"repeat,omitempty"`
+	Frequency float64   `json:"frequency,omitempty"`
+}
+
+// Turn on a switch and turn it off again after d, e.g., for fans, heaters, and
+// irrigation valves. Format is the same as for SwitchOn.
+// SwitchOnFor returns as soon as the switch is on, the "off" command is
+// scheduled like the ones of Schedule. Calling SwitchOnFor again for the same
+// switch before d has passed replaces the pending "off", a SwitchOn or
+// SwitchOff cancels it. The pending "off" only lives in this process unless
+// SetAutoOffFile is used. Its failures show up in PinHealth and Metrics.
+func (s *RCSwitch) SwitchOnFor(family, group, device string, d time.Duration) error {
+	return s.switchOnFor(Command{Family: family, Group: group, Device: device, On: true}, d)
+}
+
+func (s *RCSwitch) switchOnFor(cmd Command, d time.Duration) error {
+	if d <= 0 {
+		return errors.New("Duration has to be positive")
+	}
+	s.Lock()
+	defer s.Unlock()
+	if err := s.switchTo(context.Background(), cmd); err != nil {
+		return err
+	}
+	cmd.On = false
+	s.scheduleOff(cmd, time.Now().Add(d))
+	s.saveAutoOff()
+	return nil
+}
+
+// Keep the pending "off" commands of SwitchOnFor in the file at path, so they
+// survive restarts, e.g., of a daemon. The pending ones of an existing file are
+// scheduled again, those that became due while the process was not running are
+// sent right away. An empty path stops keeping them. Like the history file, the
+// file is written best effort, failing to write it does not fail SwitchOnFor.
+func (s *RCSwitch) SetAutoOffFile(path string) error {
+	var loaded []autoOffRecord
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		default:
+			if err := json.Unmarshal(b, &loaded); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.autoOffFile = "" // written once all are scheduled
+	for _, rec := range loaded {
+		s.scheduleOff(Command{Family: rec.Family, Group: rec.Group, Device: rec.Device, Protocol: rec.Protocol, Repeat: rec.Repeat, Frequency: rec.Frequency}, rec.At)
+	}
+	s.autoOffFile = path
+	s.saveAutoOff()
+	return nil
+}
+
+// Send the "off" command cmd at the given time, replacing a pending one of the
+// same switch. Has to be called with s locked.
+func (s *RCSwitch) scheduleOff(cmd Command, at time.Time) {
+	sw := Switch{Family: cmd.Family, Group: cmd.Group, Device: cmd.Device}
+	s.cancelAutoOff(sw)
+	e := &autoOff{cmd: cmd, at: at}
+	never := func(time.Time) time.Time { return time.Time{} }
+	e.cancel = s.runAt(at, never, func() error {
+		s.Lock()
+		defer s.Unlock()
+		if s.autoOff[sw] != e { // replaced or cancelled in the meantime
+			return nil
+		}
+		if s.isClosed() {
+			return ErrClosed // keep it in the file for the next start
+		}
+		s.cancelAutoOff(sw)
+		return s.switchTo(context.Background(), e.cmd)
+	})
+	if s.autoOff == nil {
+		s.autoOff = make(map[Switch]*autoOff)
+	}
+	s.autoOff[sw] = e
+}
+
+// Cancel a pending "off" of SwitchOnFor. Has to be called with s locked.
+func (s *RCSwitch) cancelAutoOff(sw Switch) {
+	if e, ok := s.autoOff[sw]; ok {
+		e.cancel()
+		delete(s.autoOff, sw)
+		s.saveAutoOff()
+	}
+}
+
+// Write the pending "off" commands to the auto-off file, if there is one.
+// Has to be called with s locked.
+func (s *RCSwitch) saveAutoOff() {
+	if s.autoOffFile == "" {
+		return
+	}
+	recs := make([]autoOffRecord, 0, len(s.autoOff))
+	for _, e := range s.autoOff {
+		recs = append(recs, autoOffRecord{At: e.at, Family: e.cmd.Family, Group: e.cmd.Group, Device: e.cmd.Device,
+			Protocol: e.cmd.Protocol, Repeat: e.cmd.Repeat, Frequency: e.cmd.Frequency})
+	}
+	b, err := json.Marshal(recs)
+	if err != nil {
+		return
+	}
+	// replace the file at once, a crash must not leave half of it
+	tmp := s.autoOffFile + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err == nil {
+		os.Rename(tmp, s.autoOffFile)
+	}
+}
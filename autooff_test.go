@@ -0,0 +1,71 @@
+package rcswitch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAutoOffFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "autooff.json")
+
+	s := NewRCSwitch(nil)
+	s.SetTransmitter(nopTransmitter{})
+	if err := s.SetAutoOffFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SwitchOnFor("", "1", "1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SwitchOnFor("", "1", "2", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SwitchOff("", "1", "2"); err != nil { // cancels its "off"
+		t.Fatal(err)
+	}
+
+	// a restart picks up the pending "off" of 1 1
+	restarted := NewRCSwitch(nil)
+	restarted.SetTransmitter(nopTransmitter{})
+	if err := restarted.SetAutoOffFile(path); err != nil {
+		t.Fatal(err)
+	}
+	restarted.Lock()
+	e, ok := restarted.autoOff[Switch{Group: "1", Device: "1"}]
+	n := len(restarted.autoOff)
+	restarted.Unlock()
+	if !ok || n != 1 {
+		t.Fatalf("Restart loaded %d pending off commands, expected the one of 1 1", n)
+	}
+	if e.cmd.On || time.Until(e.at) < 59*time.Minute {
+		t.Errorf("Restart loaded %+v, expected off in an hour", e)
+	}
+
+	// an "off" that became due while not running is sent right away
+	b := []byte(`[{"at":"2021-03-01T07:00:00Z","group":"1","device":"3"}]`)
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	late := NewRCSwitch(nil)
+	late.SetTransmitter(nopTransmitter{})
+	off := make(chan string, 1)
+	late.OnStateChange(func(group, device string, on bool) {
+		if !on {
+			off <- group + device
+		}
+	})
+	if err := late.SetAutoOffFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if sw := <-off; sw != "13" {
+		t.Errorf("Switched off %s, expected 13", sw)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewRCSwitch(nil).SetAutoOffFile(path); err == nil {
+		t.Error("SetAutoOffFile accepted a broken file")
+	}
+}
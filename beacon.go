@@ -0,0 +1,48 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Transmit a beacon for range testing until ctx is done.
+// Every interval a frame is sent that consists of code (bitLength bits)
+// followed by a counter of counterBits bits, which is incremented for every
+// frame and wraps around. A receiver at another location can count the beacons
+// it decodes, and gaps in the counter show which ones were lost. This helps to
+// position antennas and choose repeat counts. Code and counter together must
+// not exceed 64 bits. If sent is not nil, it is called with the counter value
+// after every frame. Beacons bypass the history and state tracking.
+// Beacon returns ctx.Err() when done, or the error of a failed transmission.
+func (s *RCSwitch) Beacon(ctx context.Context, code uint64, bitLength, counterBits int, interval time.Duration, sent func(counter uint64)) error {
+	if counterBits <= 0 {
+		return errors.New("Counter bits have to be a positive number")
+	}
+	if interval <= 0 {
+		return errors.New("Interval has to be positive")
+	}
+	if bitLength <= 0 || code>>uint(bitLength) != 0 {
+		return errors.New("Code does not fit into bit length")
+	}
+	if bitLength+counterBits > 64 {
+		return errors.New("Code and counter together must not exceed 64 bits")
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for counter := uint64(0); ; counter = (counter + 1) & (1<<uint(counterBits) - 1) {
+		if err := s.Send(code<<uint(counterBits)|counter, bitLength+counterBits); err != nil {
+			return err
+		}
+		if sent != nil {
+			sent(counter)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// BatchProgress is the progress of SwitchAllOn and SwitchAllOff after a
+// frame was sent, see OnBatchProgress.
+type BatchProgress struct {
+	Device        string // name of the device the frame was sent to, or group/device if it has none
+	Frame, Frames int    // frames sent so far, and in total
+	Done, Devices int    // devices that got all of their frames, and in total
+}
+
+// Register f to be called after every frame sent by SwitchAllOn and
+// SwitchAllOff, e.g., to show a progress bar for a scene of many devices. A
+// device is done once it got all of its frames, the state changes (see
+// OnStateChange) follow after the last frame. Like OnStateChange, f is called
+// with the RCSwitch object locked, so it must not call methods of the RCSwitch
+// object and should return quickly, e.g., by handing the progress to another
+// goroutine.
+func (s *RCSwitch) OnBatchProgress(f func(BatchProgress)) {
+	s.Lock()
+	s.progress = append(s.progress, f)
+	s.Unlock()
+}
+
+// Turn on all given devices with interleaved frames, see SwitchAllOff.
+func (s *RCSwitch) SwitchAllOn(devices []Device) error {
+	return s.SwitchAllOnCtx(context.Background(), devices)
+}
+
+// Turn off all given devices, e.g., every socket of a room. Instead of sending
+// all frames of one device after the other, the frames are interleaved
+// round-robin: the first frame of every device, then the second one, and so
+// on. Every device still gets its number of frames (Device.Repeat, or the one
+// set by SetRepeat), but it receives its first frame within the first round
+// instead of after all frames of the devices before it. Devices may use
+// different protocols. Every frame is handed to the transmitter separately,
+// so backends with a per-transmission overhead (e.g., serial) lengthen the
+// gap between frames, but all of them count as a single transmission for the
+// duty cycle limit, the lock file, LastTransmission, and Metrics. Code words
+// are checked against the code filter (see SetCodeFilter) before anything is
+// sent, and transmissions are not verified (see SetVerification). The tracked states are updated and
+// every device is recorded in the history.
+func (s *RCSwitch) SwitchAllOff(devices []Device) error {
+	return s.SwitchAllOffCtx(context.Background(), devices)
+}
+
+// Like SwitchAllOn, but can be aborted like SwitchOnCtx. An aborted
+// transmission does not change any tracked state.
+func (s *RCSwitch) SwitchAllOnCtx(ctx context.Context, devices []Device) error {
+	return s.switchAll(ctx, devices, true)
+}
+
+// Like SwitchAllOff, but can be aborted like SwitchOnCtx.
+func (s *RCSwitch) SwitchAllOffCtx(ctx context.Context, devices []Device) error {
+	return s.switchAll(ctx, devices, false)
+}
+
+func (s *RCSwitch) switchAll(ctx context.Context, devices []Device, on bool) error {
+	if len(devices) == 0 {
+		return errors.New("No devices given")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.isClosed() {
+		return ErrClosed
+	}
+
+	type job struct {
+		name   string
+		cmd    Command
+		code   string
+		protNr int
+		prot   protocol
+		ws     []waveform
+		repeat int
+	}
+	jobs := make([]job, len(devices))
+	rounds := 0
+	for i, d := range devices {
+		name := d.Name
+		if name == "" {
+			name = d.Group + "/" + d.Device
+		}
+		cmd := d.command(on)
+		code, err := s.codeWord(cmd.Family, cmd.Group, cmd.Device, on)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		prot, protNr, nrRepeat, err := s.commandProtocol(cmd)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		binary := triStateToBinary(code)
+		if err := s.checkSend(binary); err != nil {
+			return err
+		}
+		jobs[i] = job{name: name, cmd: cmd, code: code, protNr: protNr, prot: prot, ws: s.waveform(binary, prot), repeat: nrRepeat}
+		if nrRepeat > rounds {
+			rounds = nrRepeat
+		}
+	}
+
+	// Every frame is a transmission of its own with the protocol of its
+	// device, so every transmitter backend can send it. Together they are
+	// sent as a single transmission.
+	var trs []Transmission
+	var trJobs []int // index of the job of every transmission
+	for r := 0; r < rounds; r++ {
+		for i, j := range jobs {
+			if r >= j.repeat {
+				continue
+			}
+			prot := j.prot
+			if r > 0 {
+				prot.preamble = 0
+			}
+			trs = append(trs, newTransmission(j.ws, prot, 1))
+			trJobs = append(trJobs, i)
+		}
+	}
+	p := BatchProgress{Frames: len(trs), Devices: len(jobs)}
+	framesSent := make([]int, len(jobs))
+	sent := func(i int) {
+		j := trJobs[i]
+		framesSent[j]++
+		if framesSent[j] == jobs[j].repeat {
+			p.Done++
+		}
+		p.Device, p.Frame = jobs[j].name, i+1
+		for _, f := range s.progress {
+			f(p)
+		}
+	}
+	err := s.transmitAll(ctx, trs, sent)
+	for _, j := range jobs {
+		s.metrics.observeCommand(j.cmd, err)
+		s.record(j.cmd, j.code, j.protNr, err)
+	}
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs {
+		s.cancelAutoOff(Switch{Family: j.cmd.Family, Group: j.cmd.Group, Device: j.cmd.Device})
+		s.setState(j.cmd.Group, j.cmd.Device, on)
+		s.setCommand(j.cmd)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package rcswitch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Transmitter that keeps every transmission.
+type allTransmitter struct{ trs *[]Transmission }
+
+func (a allTransmitter) Transmit(_ context.Context, tr Transmission) error {
+	*a.trs = append(*a.trs, tr)
+	return nil
+}
+
+func TestSwitchAll(t *testing.T) {
+	s := NewRCSwitch(nil)
+	var got []Transmission
+	s.SetTransmitter(allTransmitter{&got})
+	if err := s.SetHistorySize(10); err != nil {
+		t.Fatal(err)
+	}
+	var progress []BatchProgress
+	s.OnBatchProgress(func(p BatchProgress) { progress = append(progress, p) })
+	devices := []Device{
+		{Name: "fan", Group: "1", Device: "1", Repeat: 3},
+		{Name: "lamp", Group: "1", Device: "2", Protocol: 2, Repeat: 2},
+	}
+	if err := s.SwitchAllOn(devices); err != nil {
+		t.Fatal(err)
+	}
+
+	// round-robin: fan, lamp, fan, lamp, fan
+	wantProtocol := []int{1, 2, 1, 2, 1}
+	if len(got) != len(wantProtocol) {
+		t.Fatalf("Sent %d frames, expected %d", len(got), len(wantProtocol))
+	}
+	for i, tr := range got {
+		if p := protocols[wantProtocol[i]-1]; tr.PulseLength != p.pulseLen*time.Microsecond || tr.Repeat != 1 {
+			t.Errorf("Frame %d: pulse length %v, %d repeats, expected protocol %d once", i, tr.PulseLength, tr.Repeat, wantProtocol[i])
+		}
+	}
+
+	wantProgress := []BatchProgress{
+		{"fan", 1, 5, 0, 2},
+		{"lamp", 2, 5, 0, 2},
+		{"fan", 3, 5, 0, 2},
+		{"lamp", 4, 5, 1, 2},
+		{"fan", 5, 5, 2, 2},
+	}
+	if len(progress) != len(wantProgress) {
+		t.Fatalf("Got %d progress events, expected %d", len(progress), len(wantProgress))
+	}
+	for i, p := range progress {
+		if p != wantProgress[i] {
+			t.Errorf("Progress %d is %+v, expected %+v", i, p, wantProgress[i])
+		}
+	}
+
+	m := s.Metrics()
+	if m.Sends[SendOK] != 1 {
+		t.Errorf("Counted %d sends, expected the batch once", m.Sends[SendOK])
+	}
+	if r := s.LastTransmission(); r.Frames != 5 {
+		t.Errorf("Report counted %d frames, expected 5", r.Frames)
+	}
+	if h := s.History(); len(h) != 2 {
+		t.Errorf("History has %d entries, expected one per device", len(h))
+	}
+	if !s.IsOn("1", "1") || !s.IsOn("1", "2") {
+		t.Error("Devices are not on")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/rck/rcswitch"
+
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/host"
+)
+
+const rcPin = 17
+
+type bridge struct {
+	rc        *rcswitch.RCSwitch
+	prefix    string
+	devices   *rcswitch.DeviceRegistry // optional
+	discovery string                   // Home Assistant discovery prefix, "" disables discovery
+}
+
+// Home Assistant MQTT discovery config of a switch.
+type discoveryConfig struct {
+	Name         string `json:"name"`
+	UniqueID     string `json:"unique_id"`
+	CommandTopic string `json:"command_topic"`
+	StateTopic   string `json:"state_topic"`
+	PayloadOn    string `json:"payload_on"`
+	PayloadOff   string `json:"payload_off"`
+}
+
+func main() {
+	broker := flag.String("broker", "tcp://localhost:1883", "MQTT broker URL")
+	clientID := flag.String("id", "rcswitch", "MQTT client ID")
+	user := flag.String("user", "", "MQTT user name")
+	password := flag.String("password", "", "MQTT password")
+	prefix := flag.String("prefix", "rcswitch", "topic prefix")
+	devices := flag.String("devices", "", "JSON or YAML file of named devices, switched via <prefix>/device/name/set")
+	discovery := flag.String("discovery", "", "Home Assistant discovery prefix (e.g., homeassistant), announces the named devices")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Bridge between MQTT and rc switches")
+		fmt.Fprintln(os.Stderr, "Synopsis: mqttbridge [flags]")
+		fmt.Fprintln(os.Stderr, "Publish ON or OFF to <prefix>/[family/]group/device/set, the state is published to .../state")
+		fmt.Fprintln(os.Stderr, "Named devices (-devices) are switched via <prefix>/device/name/set")
+		fmt.Fprintln(os.Stderr, "Example: mosquitto_pub -t rcswitch/11011/10000/set -m ON")
+		fmt.Fprintln(os.Stderr, "Example: mqttbridge -devices devices.yaml -discovery homeassistant")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	if *discovery != "" && *devices == "" {
+		log.Fatal("-discovery needs -devices")
+	}
+
+	pin := gpioreg.ByNumber(rcPin)
+	b := &bridge{
+		rc:        rcswitch.NewRCSwitch(pin),
+		prefix:    strings.TrimSuffix(*prefix, "/"),
+		discovery: strings.TrimSuffix(*discovery, "/"),
+	}
+	if *devices != "" {
+		r, err := rcswitch.LoadDeviceRegistry(*devices)
+		if err != nil {
+			log.Fatal(err)
+		}
+		b.devices = r
+		b.rc.SetDeviceRegistry(r)
+	}
+	syscall.Setpriority(syscall.PRIO_PROCESS, 0, -20)
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(*broker).
+		SetClientID(*clientID).
+		SetUsername(*user).
+		SetPassword(*password).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(b.subscribe).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Println("Connection lost:", err)
+		})
+	client := mqtt.NewClient(opts)
+	if t := client.Connect(); t.Wait() && t.Error() != nil {
+		log.Fatal(t.Error())
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	client.Disconnect(250)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := b.rc.Close(ctx); err != nil {
+		log.Println("Close:", err)
+	}
+}
+
+// Subscribe to the set topics, called on every (re)connect.
+func (b *bridge) subscribe(client mqtt.Client) {
+	filters := map[string]byte{
+		b.prefix + "/+/+/set":   1, // group/device
+		b.prefix + "/+/+/+/set": 1, // family/group/device
+	}
+	if t := client.SubscribeMultiple(filters, b.handle); t.Wait() && t.Error() != nil {
+		log.Println("Subscribe failed:", t.Error())
+	}
+
+	if b.discovery != "" {
+		b.announce(client)
+		// Home Assistant publishes "online" when it (re)starts and lost the announcements.
+		client.Subscribe(b.discovery+"/status", 1, func(client mqtt.Client, msg mqtt.Message) {
+			if string(msg.Payload()) == "online" {
+				b.announce(client)
+			}
+		})
+	}
+}
+
+// Publish the retained Home Assistant discovery configs of all named devices.
+func (b *bridge) announce(client mqtt.Client) {
+	for _, d := range b.devices.Devices() {
+		if strings.ContainsAny(d.Name, "/+#") {
+			log.Printf("Not announcing device %q, its name is not a valid topic level", d.Name)
+			continue
+		}
+		topic := b.prefix + "/device/" + d.Name
+		id := objectID(d.Name)
+		config, err := json.Marshal(discoveryConfig{
+			Name:         d.Name,
+			UniqueID:     "rcswitch_" + id,
+			CommandTopic: topic + "/set",
+			StateTopic:   topic + "/state",
+			PayloadOn:    "ON",
+			PayloadOff:   "OFF",
+		})
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		// Do not wait for the token, this might run within a handler.
+		client.Publish(b.discovery+"/switch/rcswitch/"+id+"/config", 1, true, config)
+		// without a state, Home Assistant shows the switch as unknown
+		state := "OFF"
+		if b.rc.IsOn(d.Group, d.Device) {
+			state = "ON"
+		}
+		client.Publish(topic+"/state", 1, true, state)
+	}
+}
+
+// Returns name as Home Assistant object ID, which may only consist of
+// [a-zA-Z0-9_-], other characters are replaced by _.
+func objectID(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func (b *bridge) handle(client mqtt.Client, msg mqtt.Message) {
+	levels := strings.Split(strings.TrimPrefix(msg.Topic(), b.prefix+"/"), "/")
+	levels = levels[:len(levels)-1] // "set"
+	var family, group, device, name string
+	switch {
+	case len(levels) == 2 && levels[0] == "device" && b.devices != nil:
+		name = levels[1]
+	case len(levels) == 2:
+		group, device = levels[0], levels[1]
+	case len(levels) == 3:
+		family, group, device = levels[0], levels[1], levels[2]
+	default:
+		log.Printf("Ignoring message on unexpected topic %s", msg.Topic())
+		return
+	}
+
+	var err error
+	switch strings.ToUpper(strings.TrimSpace(string(msg.Payload()))) {
+	case "ON", "1":
+		if name != "" {
+			err = b.rc.On(name)
+		} else {
+			err = b.rc.SwitchOn(family, group, device)
+		}
+	case "OFF", "0":
+		if name != "" {
+			err = b.rc.Off(name)
+		} else {
+			err = b.rc.SwitchOff(family, group, device)
+		}
+	case "TOGGLE":
+		if name != "" {
+			d, _ := b.devices.Lookup(name)
+			_, err = b.rc.Toggle(d.Family, d.Group, d.Device, rcswitch.WithProtocol(d.Protocol), rcswitch.WithRepeat(d.Repeat),
+				rcswitch.WithFrequency(d.Frequency))
+		} else {
+			_, err = b.rc.Toggle(family, group, device)
+		}
+	default:
+		log.Printf("Ignoring unknown payload %q on %s", msg.Payload(), msg.Topic())
+		return
+	}
+	if err != nil {
+		log.Printf("%s: %v", msg.Topic(), err)
+		return
+	}
+
+	if name != "" {
+		d, _ := b.devices.Lookup(name)
+		group, device = d.Group, d.Device
+	}
+	state := "OFF"
+	if b.rc.IsOn(group, device) {
+		state = "ON"
+	}
+	// Do not wait for the token, waiting within a handler can dead lock the client.
+	client.Publish(strings.TrimSuffix(msg.Topic(), "/set")+"/state", 1, true, state)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rck/rcswitch"
+
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/host"
+)
+
+const rcPin = 17
+
+// Repeatable -schedule flag.
+type schedules []string
+
+func (s *schedules) String() string     { return strings.Join(*s, ", ") }
+func (s *schedules) Set(v string) error { *s = append(*s, v); return nil }
+
+type state struct {
+	Family string `json:"family,omitempty"`
+	Group  string `json:"group"`
+	Device string `json:"device"`
+	On     bool   `json:"on"`
+}
+
+type server struct {
+	rc *rcswitch.RCSwitch
+}
+
+func main() {
+	listen := flag.String("listen", ":8080", "address to listen on")
+	devices := flag.String("devices", "", "JSON or YAML file of named devices, enables /device/")
+	historyFile := flag.String("history", "", "append every command to this file as JSON lines, GET /history shows the recent ones")
+	receiver := flag.Int("receiver", 0, "GPIO number of a receiver module, presses of remotes then update the state of the named devices")
+	autoOffFile := flag.String("autooff", "", "keep the pending \"off\" commands of on?for=duration in this file, so they survive restarts")
+	allow := flag.String("allow", "", "comma separated tri-state or binary code words, only these are sent")
+	deny := flag.String("deny", "", "comma separated tri-state or binary code words that are never sent")
+	var scheds schedules
+	flag.Var(&scheds, "schedule", "name=on|off@when, e.g., kitchen_lamp=off@23:00 or fan=on@\"@every 2h\", repeatable, needs -devices")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "HTTP daemon for rc switches")
+		fmt.Fprintln(os.Stderr, "Synopsis: rcswitchd [flags]")
+		fmt.Fprintln(os.Stderr, "POST /switch/group/device/on or .../off switches, GET /switch/group/device returns the state")
+		fmt.Fprintln(os.Stderr, "Type C switches take their family as query parameter, e.g., /switch/1/2/on?family=b")
+		fmt.Fprintln(os.Stderr, "With -devices, POST /device/name/on or .../off switches named devices")
+		fmt.Fprintln(os.Stderr, "POST .../on?for=30m turns a switch off again after 30 minutes, see -autooff")
+		fmt.Fprintln(os.Stderr, "GET /history returns the last 100 commands, newest first")
+		fmt.Fprintln(os.Stderr, "Example: curl -X POST localhost:8080/switch/11011/10000/on")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	pin := gpioreg.ByNumber(rcPin)
+	s := &server{rc: rcswitch.NewRCSwitch(pin)}
+	syscall.Setpriority(syscall.PRIO_PROCESS, 0, -20)
+
+	if *devices != "" {
+		r, err := rcswitch.LoadDeviceRegistry(*devices)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.rc.SetDeviceRegistry(r)
+		http.HandleFunc("/device/", s.handleDevice)
+	}
+	if err := s.rc.SetCodeFilter(codeList(*allow), codeList(*deny)); err != nil {
+		log.Fatal(err)
+	}
+	if err := s.rc.SetHistorySize(100); err != nil {
+		log.Fatal(err)
+	}
+	if *autoOffFile != "" {
+		if err := s.rc.SetAutoOffFile(*autoOffFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *historyFile != "" {
+		if err := s.rc.SetHistoryFile(*historyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *receiver != 0 {
+		rxPin := gpioreg.ByNumber(*receiver)
+		if rxPin == nil {
+			log.Fatalf("GPIO %d does not exist", *receiver)
+		}
+		rx, err := rcswitch.NewReceiver(rxPin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer rx.Close()
+		go s.rc.Mirror(context.Background(), rx) // returns when rc is closed
+	}
+	for _, sched := range scheds {
+		if err := s.schedule(sched); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	http.HandleFunc("/switch/", s.handleSwitch)
+	http.HandleFunc("/history", s.handleHistory)
+	srv := &http.Server{Addr: *listen}
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	// finish running requests, then let the switch drain its queue and drop the pin
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("Shutdown:", err)
+	}
+	if err := s.rc.Close(ctx); err != nil {
+		log.Println("Close:", err)
+	}
+}
+
+// Splits a comma separated -allow or -deny flag.
+func codeList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// Handles /switch/group/device and /switch/group/device/{on,off,toggle}.
+func (s *server) handleSwitch(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/switch/"), "/"), "/")
+	st := state{Family: r.URL.Query().Get("family")}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		st.Group, st.Device = parts[0], parts[1]
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		st.Group, st.Device = parts[0], parts[1]
+		var err error
+		switch parts[2] {
+		case "on":
+			var d time.Duration
+			if d, err = onFor(r); err != nil {
+				break
+			}
+			if d > 0 {
+				err = s.rc.SwitchOnFor(st.Family, st.Group, st.Device, d)
+			} else {
+				err = s.rc.SwitchOn(st.Family, st.Group, st.Device)
+			}
+		case "off":
+			err = s.rc.SwitchOff(st.Family, st.Group, st.Device)
+		case "toggle":
+			_, err = s.rc.Toggle(st.Family, st.Group, st.Device)
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case len(parts) == 2 || len(parts) == 3:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	st.On = s.rc.IsOn(st.Group, st.Device)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(st); err != nil {
+		log.Println(err)
+	}
+}
+
+// Handles /device/name/{on,off}.
+func (s *server) handleDevice(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/device/"), "/"), "/")
+	if len(parts) != 2 || (parts[1] != "on" && parts[1] != "off") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d, err := onFor(r)
+	switch {
+	case err != nil:
+	case parts[1] == "off":
+		err = s.rc.Off(parts[0])
+	case d > 0:
+		err = s.rc.OnFor(parts[0], d)
+	default:
+		err = s.rc.On(parts[0])
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Returns the duration of the "for" query parameter, after which a switched on
+// switch is turned off again, 0 if there is none.
+func onFor(r *http.Request) (time.Duration, error) {
+	v := r.URL.Query().Get("for")
+	if v == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(v)
+}
+
+// Parse a -schedule flag (name=on|off@when) and schedule it.
+func (s *server) schedule(sched string) error {
+	name, rest := split(sched, "=")
+	action, when := split(rest, "@")
+	if name == "" || (action != "on" && action != "off") || when == "" {
+		return fmt.Errorf("Schedule %q is not of the form name=on|off@when", sched)
+	}
+	_, err := s.rc.Schedule(name, action == "on", when)
+	return err
+}
+
+func split(s, sep string) (string, string) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):]
+	}
+	return s, ""
+}
+
+type historyEntry struct {
+	Time     time.Time `json:"time"`
+	Family   string    `json:"family,omitempty"`
+	Group    string    `json:"group"`
+	Device   string    `json:"device"`
+	On       bool      `json:"on"`
+	Protocol int       `json:"protocol"`
+	CodeWord string    `json:"code_word"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Handles /history.
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entries := []historyEntry{}
+	for _, e := range s.rc.History() {
+		h := historyEntry{
+			Time:     e.Time,
+			Family:   e.Command.Family,
+			Group:    e.Command.Group,
+			Device:   e.Command.Device,
+			On:       e.Command.On,
+			Protocol: e.Protocol,
+			CodeWord: e.CodeWord,
+		}
+		if e.Err != nil {
+			h.Error = e.Err.Error()
+		}
+		entries = append(entries, h)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		log.Println(err)
+	}
+}
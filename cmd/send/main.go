@@ -1,50 +1,435 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/rck/rcswitch"
+	"github.com/rck/rcswitch/gpiochip"
+	"github.com/rck/rcswitch/serial"
 
+	"periph.io/x/periph/conn/gpio"
 	"periph.io/x/periph/conn/gpio/gpioreg"
 	"periph.io/x/periph/host"
 )
 
-const rcPin = 17
+func usage() {
+	fmt.Fprintln(os.Stderr, "Test program for rc switches")
+	fmt.Fprintln(os.Stderr, "Synopsis: send [-type A|B|D] group device state")
+	fmt.Fprintln(os.Stderr, "          send -type C family group device state")
+	fmt.Fprintln(os.Stderr, "          send -type tristate code...")
+	fmt.Fprintln(os.Stderr, "          send -type raw code [bitlength]")
+	fmt.Fprintln(os.Stderr, "          send [-type A|B|C|D] [-duration d] pair [family] group device")
+	fmt.Fprintln(os.Stderr, "          send raw code... # tri-state or decimal/bits codes, - reads lines from stdin")
+	fmt.Fprintln(os.Stderr, "          send replay file # recorded by \"sniff -record file\"")
+	fmt.Fprintln(os.Stderr, "          send [-type A|B|C|D] -stdin # one \"[family] group device state\" per line")
+	fmt.Fprintln(os.Stderr, "          send [-devices file] name on|off # named devices, see rcswitch.LoadDeviceRegistry")
+	fmt.Fprintln(os.Stderr, "          send [-devices file] [-duration d] pair name")
+	fmt.Fprintln(os.Stderr, "Example: send 11011 10000 1")
+	fmt.Fprintln(os.Stderr, "Example: send kitchen on")
+	fmt.Fprintln(os.Stderr, "Example: send -type B 2 3 0")
+	fmt.Fprintln(os.Stderr, "Example: send -type C b 1 2 1")
+	fmt.Fprintln(os.Stderr, "Example: send -type tristate 0FFF0FFFFF0F")
+	fmt.Fprintln(os.Stderr, "Example: send -type raw 5393 24")
+	fmt.Fprintln(os.Stderr, "Example: send -duration 5s pair 11011 10000")
+	fmt.Fprintln(os.Stderr, "Example: send raw 0FFF0FFFFF0F 5393/24")
+	fmt.Fprintln(os.Stderr, "Example: other-tool | send raw -")
+	fmt.Fprintln(os.Stderr, "Example: printf '11011 10000 1\\n11011 01000 0\\n' | send -stdin")
+	fmt.Fprintln(os.Stderr, "Example: send -stdin -progress < scene.txt # shows \"n/m commands sent\"")
+	fmt.Fprintln(os.Stderr, "Example: send -pin 18 -protocol 2 -pulselength 620 11011 10000 1")
+	fmt.Fprintln(os.Stderr, "Example: send -gpiochip gpiochip4 11011 10000 1 # Raspberry Pi 5 on older kernels")
+	fmt.Fprintln(os.Stderr, "Example: send -serial /dev/ttyUSB0 11011 10000 1")
+	fmt.Fprintln(os.Stderr, "Example: send -dry-run -type B 2 3 1 # print instead of sending")
+	fmt.Fprintln(os.Stderr, "Example: send -export signal.sr 11011 10000 1 # open in PulseView")
+	flag.PrintDefaults()
+	os.Exit(1)
+}
 
 func main() {
+	duration := flag.Duration("duration", 10*time.Second, "how long to send the \"on\" code when pairing")
+	rcPin := flag.Int("pin", 17, "GPIO number of the transmitter")
+	protocol := flag.Int("protocol", 1, "protocol number")
+	repeat := flag.Int("repeat", 0, "number of frames per transmission, 0 is the protocol default")
+	pulseLength := flag.Int("pulselength", 0, "pulse length in microseconds, 0 is the protocol default")
+	precise := flag.Bool("precise", false, "busy-wait for exact pulses on the GPIO pin, costs a CPU core while sending, needed for short pulses like those of protocol 3")
+	typ := flag.String("type", "A", "code word type: A, B, C, D, tristate, or raw")
+	stdin := flag.Bool("stdin", false, "read switch commands from stdin, one per line")
+	showProgress := flag.Bool("progress", false, "with -stdin, read all lines before sending and show how many commands were sent")
+	chip := flag.String("gpiochip", "", "send via this GPIO character device (e.g., gpiochip0) instead of periph, -pin is the line")
+	port := flag.String("serial", "", "send via a microcontroller on this serial port (see package serial) instead of a GPIO")
+	baud := flag.Int("baud", 115200, "baud rate of -serial, 0 keeps the port settings")
+	devices := flag.String("devices", "", "JSON or YAML file of named devices (default ~/.config/rcswitch/devices.yaml)")
+	dryRun := flag.Bool("dry-run", false, "compute and print transmissions without touching the GPIO pins")
+	export := flag.String("export", "", "write the signal to this file instead of sending it, sigrok session if it ends in .sr, CSV otherwise")
+	flag.Usage = usage
 	flag.Parse()
 	args := flag.Args()
+	*typ = strings.ToLower(*typ)
 
-	if flag.NArg() != 3 {
-		fmt.Fprintln(os.Stderr, "Test program for Type A rc switches")
-		fmt.Fprintln(os.Stderr, "Synopsis: send group device state")
-		fmt.Fprintln(os.Stderr, "Example: send 11011 10000 1")
-		os.Exit(1)
+	// Number of arguments identifying a switch.
+	var switchArgs int
+	switch *typ {
+	case "a", "b", "d":
+		switchArgs = 2
+	case "c":
+		switchArgs = 3
+	case "tristate", "raw":
+	default:
+		usage()
 	}
 
-	var status bool
-	if args[2] == "1" {
-		status = true
+	raw := flag.NArg() >= 2 && args[0] == "raw"
+	pair := flag.NArg() == switchArgs+1 && args[0] == "pair"
+	replay := flag.NArg() == 2 && args[0] == "replay"
+	namedPair := flag.NArg() == 2 && args[0] == "pair"
+	named := flag.NArg() == 2 && !raw && !replay && !namedPair && switchArgs != 0 && isState(args[1])
+	switch {
+	case *stdin:
+		if flag.NArg() != 0 || switchArgs == 0 {
+			usage()
+		}
+	case raw || pair || replay || named || namedPair:
+	case *typ == "tristate":
+		if flag.NArg() == 0 {
+			usage()
+		}
+	case *typ == "raw":
+		if flag.NArg() != 1 && flag.NArg() != 2 {
+			usage()
+		}
+	case flag.NArg() != switchArgs+1:
+		usage()
 	}
 
-	if _, err := host.Init(); err != nil {
-		log.Fatal(err)
+	var registry *rcswitch.DeviceRegistry
+	if named || namedPair {
+		var err error
+		if registry, err = loadDevices(*devices); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	pin := gpioreg.ByNumber(rcPin)
+	var pin gpio.PinIO
+	if !*dryRun && *export == "" && *chip == "" && *port == "" {
+		if _, err := host.Init(); err != nil {
+			log.Fatal(err)
+		}
+		if pin = gpioreg.ByNumber(*rcPin); pin == nil {
+			log.Fatalf("GPIO %d does not exist", *rcPin)
+		}
+	}
 	rc := rcswitch.NewRCSwitch(pin)
+	switch {
+	case *dryRun:
+	case *export != "":
+		rc.SetTransmitter(exporter(*export))
+	case *chip != "":
+		tx, err := gpiochip.New(*chip, *rcPin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rc.SetTransmitter(tx)
+	case *port != "":
+		tx, err := serial.New(*port, *baud)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rc.SetTransmitter(tx)
+	}
+	if *dryRun {
+		rc.SetDryRun(true)
+		rc.SetMetricsSink(dryRunLog{})
+	}
+	if err := rc.SetProtocol(*protocol); err != nil {
+		log.Fatal(err)
+	}
+	if err := rc.SetRepeat(*repeat); err != nil {
+		log.Fatal(err)
+	}
+	if *pulseLength != 0 {
+		if err := rc.SetPulseLength(time.Duration(*pulseLength) * time.Microsecond); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *precise && pin != nil {
+		if err := rc.SetTimingMode(rcswitch.Precise); err != nil {
+			log.Fatal(err)
+		}
+	}
 	syscall.Setpriority(syscall.PRIO_PROCESS, 0, -20)
-	if status {
-		if err := rc.SwitchOn("", args[0], args[1]); err != nil {
+
+	// do not leave the transmitter keyed up when interrupted
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		rc.Close(ctx)
+		os.Exit(1)
+	}()
+
+	if pin != nil {
+		probe, err := rc.ProbeTiming()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := probe.Warning(); err != nil {
+			log.Println("Warning:", err)
+		}
+	}
+
+	if *stdin {
+		batch(rc, switchArgs, *showProgress)
+		return
+	}
+
+	if replay {
+		rec, err := rcswitch.LoadRecording(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := rc.Replay(rec); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if raw {
+		w := rcswitch.NewCodeWriter(rc)
+		if len(args) == 2 && args[1] == "-" {
+			if _, err := io.Copy(w, os.Stdin); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			for _, code := range args[1:] {
+				if _, err := fmt.Fprintln(w, code); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+		if err := w.Close(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	switch *typ {
+	case "tristate":
+		for _, code := range args {
+			if err := rc.SendTriState(code); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	case "raw":
+		code, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			log.Fatalf("Code %q is not a decimal number", args[0])
+		}
+		bitLength := 24
+		if len(args) == 2 {
+			if bitLength, err = strconv.Atoi(args[1]); err != nil {
+				log.Fatalf("Bit length %q is not a number", args[1])
+			}
+		}
+		if err := rc.Send(code, bitLength); err != nil {
 			log.Fatal(err)
 		}
-	} else {
-		if err := rc.SwitchOff("", args[0], args[1]); err != nil {
+		return
+	}
+
+	if named {
+		rc.SetDeviceRegistry(registry)
+		var err error
+		if args[1] == "on" || args[1] == "1" {
+			err = rc.On(args[0])
+		} else {
+			err = rc.Off(args[0])
+		}
+		if err != nil {
 			log.Fatal(err)
 		}
+		return
+	}
+
+	// family, group, device
+	var sw []string
+	switch {
+	case namedPair:
+		d, ok := registry.Lookup(args[1])
+		if !ok {
+			log.Fatalf("Device %s is not registered", args[1])
+		}
+		if d.Protocol != 0 {
+			if err := rc.SetProtocol(d.Protocol); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := rc.SetFrequency(d.Frequency); err != nil {
+			log.Fatal(err)
+		}
+		sw = []string{d.Family, d.Group, d.Device}
+	case pair:
+		sw = args[1:]
+	default:
+		sw = args[:switchArgs]
+	}
+	if switchArgs == 2 && !namedPair {
+		sw = append([]string{""}, sw...)
+	}
+
+	if pair || namedPair {
+		fmt.Printf("Sending \"on\" code for %s, put the socket into learning mode now\n", *duration)
+		progress := func(frames int, elapsed time.Duration) {
+			fmt.Printf("\r%d frames sent (%s/%s)", frames, elapsed.Truncate(time.Second), *duration)
+		}
+		err := rc.Pair(context.Background(), sw[0], sw[1], sw[2], *duration, progress)
+		fmt.Println()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if err := switchTo(rc, sw, args[switchArgs]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Whether arg is the state of a named device.
+func isState(arg string) bool {
+	switch arg {
+	case "on", "off", "1", "0":
+		return true
+	}
+	return false
+}
+
+// Load the named devices from path, or from the default location if path is empty.
+func loadDevices(path string) (*rcswitch.DeviceRegistry, error) {
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "rcswitch", "devices.yaml")
+	}
+	return rcswitch.LoadDeviceRegistry(path)
+}
+
+// Prints the transmissions of the dry-run mode.
+type dryRunLog struct{}
+
+func (dryRunLog) ObserveSend(rcswitch.SendResult, time.Duration) {}
+
+func (dryRunLog) ObserveTransmission(r rcswitch.TransmissionReport) {
+	fmt.Printf("Dry run: %d frames, pulse length %s, %s on the air\n", r.Frames, r.PulseLength, r.Expected)
+}
+
+func (dryRunLog) ObserveCommand(cmd rcswitch.Command, err error) {
+	state := "off"
+	if cmd.On {
+		state = "on"
+	}
+	fmt.Printf("Dry run: %s %s\n", strings.Join(strings.Fields(cmd.Family+" "+cmd.Group+" "+cmd.Device), " "), state)
+}
+
+// Writes the signal of every transmission to a file, a later one overwrites an
+// earlier one.
+type exporter string
+
+func (e exporter) Transmit(ctx context.Context, tr rcswitch.Transmission) error {
+	f, err := os.Create(string(e))
+	if err != nil {
+		return err
+	}
+	write := rcswitch.WriteCSV
+	if strings.HasSuffix(string(e), ".sr") {
+		write = rcswitch.WriteSigrok
+	}
+	if err := write(f, tr); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Switch sw (family, group, device) to state "1" (on) or anything else (off).
+func switchTo(rc *rcswitch.RCSwitch, sw []string, state string) error {
+	if state == "1" {
+		return rc.SwitchOn(sw[0], sw[1], sw[2])
+	}
+	return rc.SwitchOff(sw[0], sw[1], sw[2])
+}
+
+// Read one command per line from stdin and send them one after the other.
+// Failed lines are logged, the exit code is 1 if any line failed. With
+// progress, all lines are read before sending, so the number of commands is
+// known, and the progress is shown after every command.
+func batch(rc *rcswitch.RCSwitch, switchArgs int, progress bool) {
+	type command struct {
+		line   int
+		fields []string
+	}
+	failed := false
+	send := func(n, total int, c command) {
+		sw := c.fields[:switchArgs]
+		if switchArgs == 2 {
+			sw = append([]string{""}, sw...)
+		}
+		err := switchTo(rc, sw, c.fields[switchArgs])
+		if progress {
+			fmt.Fprintf(os.Stderr, "\r%d/%d commands sent, last %s", n, total, strings.Join(c.fields, " "))
+		}
+		if err != nil {
+			if progress {
+				fmt.Fprintln(os.Stderr)
+			}
+			log.Printf("Line %d: %v", c.line, err)
+			failed = true
+		}
+	}
+
+	var commands []command
+	scanner := bufio.NewScanner(os.Stdin)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != switchArgs+1 {
+			log.Printf("Line %d: expected %d fields, got %d", line, switchArgs+1, len(fields))
+			failed = true
+			continue
+		}
+		if progress {
+			commands = append(commands, command{line, fields})
+		} else {
+			send(0, 0, command{line, fields})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	for i, c := range commands {
+		send(i+1, len(commands), c)
+	}
+	if progress && len(commands) > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	if failed {
+		os.Exit(1)
 	}
 }
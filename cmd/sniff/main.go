@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rck/rcswitch"
+
+	"periph.io/x/periph/conn/gpio/gpioreg"
+	"periph.io/x/periph/host"
+)
+
+const rcPin = 27
+
+func main() {
+	record := flag.String("record", "", "capture the raw signal into this file instead of decoding it")
+	timeout := flag.Duration("timeout", 10*time.Second, "how long to wait for a signal to record")
+	dedupe := flag.Duration("dedupe", 0, "print a repeated code only once if it repeats within this duration, e.g., 500ms")
+	protocol := flag.Int("protocol", 0, "only print codes of this protocol, 0 prints all")
+	analyze := flag.String("analyze", "", "suggest the protocol of a recording instead of receiving")
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Prints every code received by a receiver module")
+		fmt.Fprintln(os.Stderr, "Synopsis: sniff [-dedupe d] [-protocol n]")
+		fmt.Fprintln(os.Stderr, "          sniff -record file [-timeout d]")
+		fmt.Fprintln(os.Stderr, "          sniff -analyze file")
+		fmt.Fprintln(os.Stderr, "Recordings can be replayed by \"send replay file\"")
+		os.Exit(1)
+	}
+
+	if *analyze != "" {
+		rec, err := rcswitch.LoadRecording(*analyze)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := printAnalysis(rec); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	pin := gpioreg.ByNumber(rcPin)
+	if *record != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		fmt.Println("Press the button on the remote now")
+		rec, err := rcswitch.Record(ctx, pin, 100*time.Millisecond)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := rec.Save(*record); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Recorded %d pulses\n", len(rec.Pulses))
+		if err := printAnalysis(rec); err != nil {
+			fmt.Println("No protocol found:", err)
+		}
+		return
+	}
+
+	rx, err := rcswitch.NewReceiver(pin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := rx.SetDeduplication(*dedupe); err != nil {
+		log.Fatal(err)
+	}
+	if *protocol != 0 {
+		if err := rx.SetReceiveFilter(rcswitch.ReceiveFilter{Protocols: []int{*protocol}}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	syscall.Setpriority(syscall.PRIO_PROCESS, 0, -20)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		rx.Close()
+	}()
+
+	for c := range rx.Codes() {
+		binary := fmt.Sprintf("%0*b", c.BitLength, c.Value)
+		fmt.Printf("Decimal: %d (%dBit) Binary: %s Tri-State: %s PulseLength: %d microseconds Protocol: %d\n",
+			c.Value, c.BitLength, binary, binaryToTriState(binary), c.PulseLength.Microseconds(), c.Protocol)
+	}
+}
+
+func printAnalysis(rec rcswitch.Recording) error {
+	a, err := rec.Analyze()
+	if err != nil {
+		return err
+	}
+	p := a.Protocol
+	if p.Number != 0 {
+		fmt.Printf("Protocol: %d\n", p.Number)
+	} else {
+		fmt.Println("Protocol: unknown")
+	}
+	fmt.Printf("PulseLength: %d microseconds Sync: %d:%d Zero: %d:%d One: %d:%d Inverted: %t\n",
+		p.PulseLength.Microseconds(), p.Sync.High, p.Sync.Low, p.Zero.High, p.Zero.Low, p.One.High, p.One.Low, p.Inverted)
+	fmt.Printf("Frames: %d Skew: %d microseconds\n", a.Frames, a.Skew.Microseconds())
+	for _, code := range a.Codes {
+		fmt.Printf("Binary: %s Tri-State: %s\n", code, binaryToTriState(code))
+	}
+	return nil
+}
+
+func binaryToTriState(binary string) string {
+	if len(binary)%2 != 0 {
+		return "not applicable"
+	}
+
+	var tristate string
+	for i := 0; i < len(binary); i += 2 {
+		switch binary[i : i+2] {
+		case "00":
+			tristate += "0"
+		case "11":
+			tristate += "1"
+		case "01":
+			tristate += "F"
+		default:
+			return "not applicable"
+		}
+	}
+	return tristate
+}
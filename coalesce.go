@@ -0,0 +1,61 @@
+package rcswitch
+
+import (
+	"context"
+	"sync"
+)
+
+// A command waiting for the RCSwitch object to become available.
+type pendingCommand struct {
+	done chan struct{}
+	err  error
+}
+
+type pendingCommands struct {
+	commands map[Command]*pendingCommand
+	sync.Mutex
+}
+
+// Send cmd like switchTo, but coalesce it with an identical command that is
+// still waiting for its transmission to start (e.g., button mashing or retrying
+// clients). All callers of coalesced commands get the result of the single
+// transmission. Commands are never coalesced with a transmission that already
+// started, as it might have been missed by the receiver. If the single
+// transmission got aborted by its context, the other callers try on their own.
+// Has to be called with s unlocked.
+func (s *RCSwitch) switchCoalesced(ctx context.Context, cmd Command) error {
+	s.pending.Lock()
+	if p, ok := s.pending.commands[cmd]; ok {
+		s.pending.Unlock()
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		// Queued commands are still sent while closing, unlike the one they
+		// were coalesced with.
+		queued := p.err == ErrClosed && ctx.Value(queuedKey{}) != nil
+		if p.err == context.Canceled || p.err == context.DeadlineExceeded || queued {
+			return s.switchCoalesced(ctx, cmd)
+		}
+		return p.err
+	}
+	if s.pending.commands == nil {
+		s.pending.commands = make(map[Command]*pendingCommand)
+	}
+	p := &pendingCommand{done: make(chan struct{})}
+	s.pending.commands[cmd] = p
+	s.pending.Unlock()
+
+	s.Lock()
+	s.pending.Lock()
+	delete(s.pending.commands, cmd)
+	s.pending.Unlock()
+	s.draining = ctx.Value(queuedKey{}) != nil
+	p.err = s.switchTo(ctx, cmd)
+	s.draining = false
+	s.Unlock()
+
+	close(p.done)
+	return p.err
+}
@@ -0,0 +1,82 @@
+package rcswitch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Placeholder in code word templates, see SetCodeWordTemplate.
+const codeWordPlaceholder = "{}"
+
+type codeWords struct {
+	on, off string
+}
+
+// Define a switch by its "on" and "off" tri-state code words, for sockets whose
+// encoding does not match any of the Type A-D layouts. Family, group, and device
+// of sw are free-form names for the switch (e.g., {Group: "garden", Device: "1"}),
+// afterwards the switch can be used with SwitchOn, SwitchOff, IsOn and so on.
+// A definition takes precedence over Type A-D encoding for the same arguments.
+// Empty on and off remove the definition.
+func (s *RCSwitch) SetCodeWords(sw Switch, on, off string) error {
+	if on == "" && off == "" {
+		s.Lock()
+		delete(s.custom, sw)
+		s.Unlock()
+		return nil
+	}
+	for _, c := range []string{on, off} {
+		if err := validTriState(c); err != nil {
+			return err
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.custom == nil {
+		s.custom = make(map[Switch]codeWords)
+	}
+	s.custom[sw] = codeWords{on: on, off: off}
+	return nil
+}
+
+// Like SetCodeWords, but both code words are built from a template in which the
+// placeholder "{}" is replaced by on or off respectively. For example the
+// template "0FF0F0FF{}" with on "0F" and off "F0" gives "0FF0F0FF0F" and "0FF0F0FFF0".
+func (s *RCSwitch) SetCodeWordTemplate(sw Switch, template, on, off string) error {
+	if strings.Count(template, codeWordPlaceholder) != 1 {
+		return fmt.Errorf("Template %q has to contain the placeholder %s exactly once", template, codeWordPlaceholder)
+	}
+	return s.SetCodeWords(sw,
+		strings.Replace(template, codeWordPlaceholder, on, 1),
+		strings.Replace(template, codeWordPlaceholder, off, 1))
+}
+
+// Returns the code word of a switch, taking definitions by SetCodeWords and the
+// encoder set by SetEncoder into account. Has to be called with s locked.
+func (s *RCSwitch) codeWord(family, group, device string, on bool) (string, error) {
+	if c, ok := s.custom[Switch{Family: family, Group: group, Device: device}]; ok {
+		if on {
+			return c.on, nil
+		}
+		return c.off, nil
+	}
+	if s.encoder == nil {
+		return getCodeWord(family, group, device, on)
+	}
+	code, err := s.encoder.Encode(family, group, device, on)
+	if err != nil {
+		return "", err
+	}
+	if err := validTriState(code); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+func validTriState(code string) error {
+	if code == "" || strings.Trim(code, "01F") != "" {
+		return fmt.Errorf("Code word %q has to be a non-empty tri-state string of 0, 1, and F", code)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Pulse lengths tried by DiscoverProtocol relative to the protocol's pulse length.
+// Cheap sockets and remotes often deviate from the nominal timing.
+var discoverPulseFactors = []float64{1, 0.85, 1.15}
+
+// Find the protocol of a socket by trial.
+// For every protocol and a small set of pulse lengths around its nominal one,
+// the "on" code of the switch is sent and confirm is called. The user watching
+// the socket answers whether it turned on. The socket should be off before
+// calling DiscoverProtocol. Format of family, group, and device is the same as for SwitchOn.
+// The first combination confirmed becomes the protocol of the RCSwitch object
+// and is returned, PulseLength might differ from the protocol's nominal one.
+// The RCSwitch object is not locked while confirm is called.
+func (s *RCSwitch) DiscoverProtocol(family, group, device string, confirm func(ProtocolInfo) bool) (ProtocolInfo, error) {
+	s.Lock()
+	code, err := s.codeWord(family, group, device, true)
+	s.Unlock()
+	if err != nil {
+		return ProtocolInfo{}, err
+	}
+	binary := triStateToBinary(code)
+
+	infos := Protocols()
+	for i, prot := range protocols {
+		for _, f := range discoverPulseFactors {
+			p := prot
+			p.pulseLen = time.Duration(float64(prot.pulseLen) * f)
+			info := infos[i]
+			info.PulseLength = p.pulseLen * time.Microsecond
+
+			s.Lock()
+			err := s.sendProtocol(context.Background(), binary, p)
+			s.Unlock()
+			if err != nil {
+				return ProtocolInfo{}, err
+			}
+
+			if confirm(info) {
+				s.Lock()
+				s.protocol = p
+				s.protocolNr = i + 1
+				s.setState(group, device, true)
+				s.Unlock()
+				return info, nil
+			}
+		}
+	}
+
+	return ProtocolInfo{}, errors.New("No protocol was confirmed to work")
+}
@@ -0,0 +1,33 @@
+package rcswitch
+
+import (
+	"context"
+	"time"
+)
+
+// Enable or disable the dry-run mode, it is disabled by default.
+// In dry-run mode code words and waveforms are computed as usual, but the
+// transmitter is never used: the pin is not toggled, not even by Close, and a
+// lock file (see SetLockFile) is not taken. Instead every transmission just
+// takes its nominal duration, so timing, the duty cycle limit, the history, and
+// metrics behave as with real hardware. LastTransmission and a ReportSink get
+// reports with DryRun set. Useful for CI, development on machines without GPIO
+// pins (see NewRCSwitch with a nil pin), and to check code words before
+// touching the hardware.
+func (s *RCSwitch) SetDryRun(enabled bool) {
+	s.Lock()
+	s.dryRun = enabled
+	s.Unlock()
+}
+
+// Wait for the nominal duration of tr, or until ctx is done.
+func simulate(ctx context.Context, tr Transmission) error {
+	t := time.NewTimer(tr.duration())
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
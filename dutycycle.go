@@ -0,0 +1,85 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDutyCycle is returned if a single transmission is longer than the air
+// time allowed within the whole window, see SetDutyCycleLimit.
+var ErrDutyCycle = errors.New("Transmission exceeds the duty cycle limit")
+
+type airTime struct {
+	end time.Time
+	d   time.Duration
+}
+
+type dutyCycle struct {
+	budget time.Duration // air time allowed within window, 0 is unlimited
+	window time.Duration
+	sends  []airTime // within the window, oldest first
+}
+
+// Limit the air time to percent of every window, e.g., 1% per hour as
+// required for most of the 433MHz ISM band in the EU. A transmission that
+// would exceed the limit is delayed until older transmissions left the window,
+// which blocks all other transmissions of the RCSwitch object as well. The
+// ctx of the *Ctx methods aborts waiting. Transmissions that are longer than
+// the whole budget fail with ErrDutyCycle. The air time is the duration of a
+// transmission including low periods, which is a conservative estimate for
+// OOK transmitters. Note that Pair and StartContinuous are throttled as well.
+// A percent of 0 disables the limit, which is the default.
+func (s *RCSwitch) SetDutyCycleLimit(percent float64, window time.Duration) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("Duty cycle %g%% is not in the range of 0 to 100", percent)
+	}
+	if percent > 0 && window <= 0 {
+		return errors.New("Duty cycle window has to be positive")
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.duty.budget = time.Duration(percent / 100 * float64(window))
+	s.duty.window = window
+	return nil
+}
+
+// Wait until a transmission of duration d is within the limit.
+func (dc *dutyCycle) wait(ctx context.Context, d time.Duration) error {
+	if dc.budget == 0 {
+		return nil
+	}
+	if d > dc.budget {
+		return ErrDutyCycle
+	}
+	for {
+		now := time.Now()
+		var used time.Duration
+		i := 0
+		for ; i < len(dc.sends) && now.Sub(dc.sends[i].end) >= dc.window; i++ {
+		}
+		dc.sends = dc.sends[i:]
+		for _, a := range dc.sends {
+			used += a.d
+		}
+		if used+d <= dc.budget {
+			return nil
+		}
+
+		t := time.NewTimer(dc.sends[0].end.Add(dc.window).Sub(now))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+func (dc *dutyCycle) record(d time.Duration) {
+	if dc.budget == 0 {
+		return
+	}
+	dc.sends = append(dc.sends, airTime{end: time.Now(), d: d})
+}
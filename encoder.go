@@ -0,0 +1,121 @@
+package rcswitch
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An Encoder builds the tri-state code word (0, 1, and F) of a switch from the
+// family, group, and device arguments of SwitchOn and SwitchOff. Encoders for
+// sockets not covered by Type A-D can be added with RegisterEncoder.
+type Encoder interface {
+	Encode(family, group, device string, on bool) (string, error)
+}
+
+// EncoderFunc adapts a function to the Encoder interface.
+type EncoderFunc func(family, group, device string, on bool) (string, error)
+
+// Encode calls f.
+func (f EncoderFunc) Encode(family, group, device string, on bool) (string, error) {
+	return f(family, group, device, on)
+}
+
+// Name of the default encoder, which picks Type A, B, C, or D by the format
+// of the arguments as described for SwitchOn.
+const AutoEncoder = "auto"
+
+var encoders = struct {
+	m map[string]Encoder
+	sync.Mutex
+}{m: map[string]Encoder{
+	AutoEncoder: EncoderFunc(getCodeWord),
+	"a": EncoderFunc(func(family, group, device string, on bool) (string, error) {
+		if family != "" {
+			return "", errors.New("Type A does not have a family")
+		}
+		return getCodeWordA(group, device, on)
+	}),
+	"b": EncoderFunc(func(family, group, device string, on bool) (string, error) {
+		if family != "" {
+			return "", errors.New("Type B does not have a family")
+		}
+		g, err := strconv.Atoi(group)
+		if err != nil {
+			return "", fmt.Errorf("Group %q is not a number", group)
+		}
+		d, err := strconv.Atoi(device)
+		if err != nil {
+			return "", fmt.Errorf("Device %q is not a number", device)
+		}
+		return getCodeWordB(g, d, on)
+	}),
+	"c": EncoderFunc(getCodeWordC),
+	"d": EncoderFunc(func(family, group, device string, on bool) (string, error) {
+		if family != "" {
+			return "", errors.New("Type D does not have a family")
+		}
+		d, err := strconv.Atoi(device)
+		if err != nil {
+			return "", fmt.Errorf("Device %q is not a number", device)
+		}
+		return getCodeWordD(group, d, on)
+	}),
+}}
+
+// Register an encoder under a name, so it can be selected with SetEncoder.
+// Names are case-insensitive. The built-in encoders are "auto" and "a" to "d"
+// for Type A-D, they can not be replaced.
+func RegisterEncoder(name string, e Encoder) error {
+	name = strings.ToLower(name)
+	if name == "" || e == nil {
+		return errors.New("Encoder name and encoder must not be empty")
+	}
+	encoders.Lock()
+	defer encoders.Unlock()
+	if isBuiltinEncoder(name) {
+		return fmt.Errorf("Encoder %s is built-in and can not be replaced", name)
+	}
+	encoders.m[name] = e
+	return nil
+}
+
+// Returns the names of all registered encoders, sorted.
+func Encoders() []string {
+	encoders.Lock()
+	defer encoders.Unlock()
+	names := make([]string, 0, len(encoders.m))
+	for name := range encoders.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isBuiltinEncoder(name string) bool {
+	switch name {
+	case AutoEncoder, "a", "b", "c", "d":
+		return true
+	}
+	return false
+}
+
+// Set the encoder used by SwitchOn, SwitchOff, and all other commands taking
+// family, group, and device, by its name (see RegisterEncoder). The default is
+// AutoEncoder. Definitions by SetCodeWords still take precedence.
+func (s *RCSwitch) SetEncoder(name string) error {
+	encoders.Lock()
+	e, ok := encoders.m[strings.ToLower(name)]
+	encoders.Unlock()
+	if !ok {
+		return fmt.Errorf("Encoder %s is not registered, registered are %s", name, strings.Join(Encoders(), ", "))
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.encoder = e
+	return nil
+}
@@ -0,0 +1,66 @@
+package rcswitch
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ESPHomeSwitch is a named switch to be exported by WriteESPHome.
+// Format of Family, Group, and Device is the same as for SwitchOn.
+type ESPHomeSwitch struct {
+	Name                  string
+	Family, Group, Device string
+}
+
+// Protocols of this package ESPHome's transmit_rc_switch_raw knows by the same
+// number, it only has the ones of upstream rc-switch.
+const espHomeProtocols = 7
+
+// Write the given switches as ESPHome template switches to w.
+// The on/off actions use remote_transmitter.transmit_rc_switch_raw with the
+// binary code words SwitchOn and SwitchOff would send, including custom code
+// words (see SetCodeWords) and the encoder (see SetEncoder), using the given
+// protocol number and repeat count. This way a setup prototyped with this
+// package can be moved to an ESP node without sniffing the codes again. The
+// output is a complete "switch:" section, a remote_transmitter still has to be
+// configured. ESPHome only knows protocols 1 to 7.
+func (s *RCSwitch) WriteESPHome(w io.Writer, protocol, repeat int, switches []ESPHomeSwitch) error {
+	if protocol <= 0 || protocol > espHomeProtocols {
+		return fmt.Errorf("Protocol %d is not supported by ESPHome, supported are 1 to %d", protocol, espHomeProtocols)
+	}
+	if repeat <= 0 {
+		return errors.New("Repeat has to be a positive number")
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "switch:")
+	for _, sw := range switches {
+		s.Lock()
+		on, err := s.codeWord(sw.Family, sw.Group, sw.Device, true)
+		if err != nil {
+			s.Unlock()
+			return fmt.Errorf("%s: %v", sw.Name, err)
+		}
+		off, err := s.codeWord(sw.Family, sw.Group, sw.Device, false)
+		s.Unlock()
+		if err != nil {
+			return fmt.Errorf("%s: %v", sw.Name, err)
+		}
+
+		fmt.Fprintln(bw, "  - platform: template")
+		fmt.Fprintf(bw, "    name: %s\n", strconv.Quote(sw.Name))
+		fmt.Fprintln(bw, "    optimistic: true")
+		for _, a := range []struct{ action, code string }{{"turn_on_action", on}, {"turn_off_action", off}} {
+			fmt.Fprintf(bw, "    %s:\n", a.action)
+			fmt.Fprintln(bw, "      - remote_transmitter.transmit_rc_switch_raw:")
+			fmt.Fprintf(bw, "          code: '%s'\n", triStateToBinary(a.code))
+			fmt.Fprintf(bw, "          protocol: %d\n", protocol)
+			fmt.Fprintln(bw, "          repeat:")
+			fmt.Fprintf(bw, "            times: %d\n", repeat)
+		}
+	}
+	return bw.Flush()
+}
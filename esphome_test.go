@@ -0,0 +1,32 @@
+package rcswitch
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteESPHome(t *testing.T) {
+	s := NewRCSwitch(nil)
+	if err := s.SetCodeWords(Switch{Group: "lamp"}, "0F0F", "0F00"); err != nil {
+		t.Fatal(err)
+	}
+	switches := []ESPHomeSwitch{{Name: "Fan", Group: "1", Device: "1"}, {Name: "Lamp", Group: "lamp"}}
+
+	var b bytes.Buffer
+	if err := s.WriteESPHome(&b, 1, 10, switches); err != nil {
+		t.Fatal(err)
+	}
+	// Type B group 1 device 1 and the custom code words of the lamp
+	for _, code := range []string{"000101010001010101010101", "000101010001010101010100", "00010001", "00010000"} {
+		if !strings.Contains(b.String(), "code: '"+code+"'") {
+			t.Errorf("Code %s is missing in\n%s", code, b.String())
+		}
+	}
+
+	for _, protocol := range []int{0, 8, 12} {
+		if err := s.WriteESPHome(&b, protocol, 10, switches); err == nil {
+			t.Errorf("Protocol %d was accepted", protocol)
+		}
+	}
+}
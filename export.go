@@ -0,0 +1,130 @@
+package rcswitch
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Idle time written before and after the signal by WriteSigrok, so the first
+// and the last edge are visible.
+const exportIdle = time.Millisecond
+
+// A period of constant level of a signal.
+type period struct {
+	high bool
+	d    time.Duration
+}
+
+// Returns the transmission SendBinary would put on the air for the binary code
+// word, using the current protocol, pulse length, repeat count, and gap
+// between frames. Pass it to WriteCSV or WriteSigrok to compare the intended
+// signal against a capture of the transmitter.
+func (s *RCSwitch) Transmission(binary string) (Transmission, error) {
+	if binary == "" || strings.Trim(binary, "01") != "" {
+		return Transmission{}, fmt.Errorf("Code word %q has to be a non-empty binary string of 0 and 1", binary)
+	}
+	s.Lock()
+	defer s.Unlock()
+	tr := newTransmission(binaryToWaveForm(binary, s.protocol), s.protocol, s.repeat(s.protocol))
+	tr.Gap = s.repeatGap
+	return tr, nil
+}
+
+// Write the signal of tr as CSV to w, one line per level change with the time
+// in microseconds since the start and the level on the air (1 is carrier on):
+//
+//	time_us,level
+//	0,1
+//	350,0
+//
+// The last line is the end of the transmission, when the transmitter is off again.
+func WriteCSV(w io.Writer, tr Transmission) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "time_us,level")
+	var t time.Duration
+	for _, p := range tr.periods() {
+		level := 0
+		if p.high {
+			level = 1
+		}
+		fmt.Fprintf(bw, "%d,%d\n", t.Microseconds(), level)
+		t += p.d
+	}
+	fmt.Fprintf(bw, "%d,0\n", t.Microseconds())
+	return bw.Flush()
+}
+
+// Write the signal of tr to w as sigrok session file (.sr), which can be
+// opened by PulseView and sigrok-cli. It has one channel, D0, sampled at 1MHz.
+func WriteSigrok(w io.Writer, tr Transmission) error {
+	z := zip.NewWriter(w)
+	files := []struct{ name, content string }{
+		{"version", "2"},
+		{"metadata", "[global]\nsigrok version=0.5.2\n\n[device 1]\ncapturefile=logic-1\n" +
+			"total probes=1\nsamplerate=1 MHz\ntotal analog=0\nprobe1=D0\nunitsize=1\n"},
+	}
+	for _, f := range files {
+		fw, err := z.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+
+	fw, err := z.Create("logic-1-1")
+	if err != nil {
+		return err
+	}
+	ps := append([]period{{d: exportIdle}}, tr.periods()...)
+	ps = append(ps, period{d: exportIdle})
+	for _, p := range ps {
+		var b byte
+		if p.high {
+			b = 1
+		}
+		if _, err := fw.Write(bytes.Repeat([]byte{b}, int(p.d/time.Microsecond))); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// Returns the periods of constant level on the air of tr, adjacent periods of
+// the same level are merged.
+func (tr Transmission) periods() []period {
+	var ps []period
+	add := func(high bool, d time.Duration) {
+		switch {
+		case d == 0:
+		case len(ps) > 0 && ps[len(ps)-1].high == high:
+			ps[len(ps)-1].d += d
+		default:
+			ps = append(ps, period{high: high, d: d})
+		}
+	}
+	pulses := func(high bool, n int) {
+		add(high != tr.Inverted, time.Duration(n)*tr.PulseLength)
+	}
+
+	for i := 0; i < tr.Preamble; i++ {
+		pulses(true, 1)
+		pulses(false, 1)
+	}
+	for r := 0; r < tr.Repeat; r++ {
+		if r > 0 {
+			add(false, tr.Gap)
+		}
+		for _, w := range tr.Frame {
+			pulses(true, w.High)
+			pulses(false, w.Low)
+		}
+	}
+	return ps
+}
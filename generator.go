@@ -0,0 +1,60 @@
+package rcswitch
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Pattern is a test pattern output by Generate.
+type Pattern int
+
+const (
+	// Square wave, one pulse length high followed by one pulse length low.
+	PatternSquare Pattern = iota
+	// Alternating "0" and "1" bits of the current protocol.
+	PatternBits
+	// Continuous sync bits of the current protocol.
+	PatternSync
+)
+
+// Approximate duration of a frame sent by Generate. Generate checks whether it
+// is done between frames, so short patterns are repeated within a frame.
+const generateFrame = 20 * time.Millisecond
+
+// Output a test pattern on the pin until ctx is done.
+// This turns the transmitter into a signal generator, so timing and transmitter
+// hardware can be verified with a logic analyzer or an SDR independent of any
+// socket. Timing is the one of the current protocol, a preamble is not sent.
+// Like StartContinuous, all other methods of the RCSwitch object block while
+// generating. Generate returns ctx.Err() when done, or the error of a failed transmission.
+func (s *RCSwitch) Generate(ctx context.Context, pattern Pattern) error {
+	s.Lock()
+	defer s.Unlock()
+
+	prot := s.protocol
+	prot.preamble = 0
+	var ws []waveform
+	switch pattern {
+	case PatternSquare:
+		ws = []waveform{{1, 1}}
+	case PatternBits:
+		ws = []waveform{prot.zeroBit, prot.oneBit}
+	case PatternSync:
+		ws = []waveform{prot.syncBit}
+	default:
+		return fmt.Errorf("Pattern %d is not supported", pattern)
+	}
+
+	var pulses int
+	for _, w := range ws {
+		pulses += w.high + w.low
+	}
+	n := int(generateFrame / (time.Duration(pulses) * prot.pulseLen * time.Microsecond))
+	frame := make([]waveform, 0, len(ws)*(n+1))
+	for i := 0; i <= n; i++ {
+		frame = append(frame, ws...)
+	}
+
+	return s.transmitContinuously(ctx, frame, prot, 0, nil)
+}
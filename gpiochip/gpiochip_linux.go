@@ -0,0 +1,106 @@
+package gpiochip
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/rck/rcswitch"
+)
+
+// GPIO character device uAPI v2, see linux/gpio.h.
+const (
+	linesMax    = 64
+	nameSize    = 32
+	numAttrsMax = 10
+
+	lineFlagOutput         = 1 << 3
+	lineAttrIDOutputValues = 2
+
+	getLineIoctl       = 0xc250b407 // _IOWR(0xB4, 0x07, struct gpio_v2_line_request)
+	lineSetValuesIoctl = 0xc010b40f // _IOWR(0xB4, 0x0F, struct gpio_v2_line_values)
+)
+
+type lineAttribute struct {
+	id      uint32
+	padding uint32
+	values  uint64 // union of flags, values, and debounce_period_us
+}
+
+type lineConfigAttribute struct {
+	attr lineAttribute
+	mask uint64
+}
+
+type lineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [numAttrsMax]lineConfigAttribute
+}
+
+type lineRequest struct {
+	offsets         [linesMax]uint32
+	consumer        [nameSize]byte
+	config          lineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type lineValues struct {
+	bits uint64
+	mask uint64
+}
+
+// Request line (the offset within the chip, which is the Broadcom GPIO number
+// on Raspberry Pis) of chip (e.g., "/dev/gpiochip0" or just "gpiochip0") as
+// output and create a Transmitter for it. The line is driven low. It is
+// requested exclusively, so it fails if the line is used by another program.
+func New(chip string, line int) (*Transmitter, error) {
+	if line < 0 {
+		return nil, fmt.Errorf("Line %d must not be negative", line)
+	}
+	if !strings.ContainsRune(chip, '/') {
+		chip = "/dev/" + chip
+	}
+	f, err := os.Open(chip)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // the line request stays valid
+
+	req := lineRequest{numLines: 1}
+	req.offsets[0] = uint32(line)
+	copy(req.consumer[:nameSize-1], "rcswitch")
+	req.config.flags = lineFlagOutput
+	req.config.numAttrs = 1
+	req.config.attrs[0] = lineConfigAttribute{
+		attr: lineAttribute{id: lineAttrIDOutputValues, values: 0},
+		mask: 1,
+	}
+	if err := ioctl(f.Fd(), getLineIoctl, unsafe.Pointer(&req)); err != nil {
+		return nil, fmt.Errorf("Requesting line %d of %s: %v", line, chip, err)
+	}
+	rcswitch.CalibrateWaitUntil()
+	return &Transmitter{line: os.NewFile(uintptr(req.fd), fmt.Sprintf("%s line %d", chip, line))}, nil
+}
+
+// Set the level of the line.
+func (t *Transmitter) set(high bool) error {
+	v := lineValues{mask: 1}
+	if high {
+		v.bits = 1
+	}
+	return ioctl(t.line.Fd(), lineSetValuesIoctl, unsafe.Pointer(&v))
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package gpiochip
+
+import "errors"
+
+var errNotLinux = errors.New("The GPIO character device is only available on Linux")
+
+// New fails, the GPIO character device is Linux only.
+func New(chip string, line int) (*Transmitter, error) {
+	return nil, errNotLinux
+}
+
+func (t *Transmitter) set(high bool) error {
+	return errNotLinux
+}
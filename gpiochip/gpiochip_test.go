@@ -0,0 +1,36 @@
+package gpiochip
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rck/rcswitch"
+)
+
+func TestEdges(t *testing.T) {
+	const p = 100 * time.Microsecond
+	frame := []rcswitch.Waveform{{High: 1, Low: 3}, {High: 3, Low: 1}}
+	tests := []struct {
+		name string
+		tr   rcswitch.Transmission
+		want []step
+	}{
+		{"normal", rcswitch.Transmission{Frame: frame, PulseLength: p, Repeat: 1},
+			[]step{{true, p}, {false, 3 * p}, {true, 3 * p}, {false, p}}},
+		{"inverted", rcswitch.Transmission{Frame: frame, PulseLength: p, Inverted: true, Repeat: 1},
+			[]step{{false, p}, {true, 3 * p}, {false, 3 * p}, {true, p}}},
+		{"preamble", rcswitch.Transmission{Frame: frame[:1], PulseLength: p, Preamble: 2, Repeat: 1},
+			[]step{{true, p}, {false, p}, {true, p}, {false, p}, {true, p}, {false, 3 * p}}},
+		{"gap", rcswitch.Transmission{Frame: frame[:1], PulseLength: p, Repeat: 2, Gap: time.Millisecond},
+			[]step{{true, p}, {false, 3*p + time.Millisecond}, {true, p}, {false, 3 * p}}},
+		// The gap is silence, it must not be inverted.
+		{"inverted gap", rcswitch.Transmission{Frame: frame[:1], PulseLength: p, Inverted: true, Repeat: 2, Gap: time.Millisecond},
+			[]step{{false, p}, {true, 3 * p}, {false, time.Millisecond + p}, {true, 3 * p}}},
+	}
+	for _, tt := range tests {
+		if got := edges(tt.tr); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: edges() = %v, expected %v", tt.name, got, tt.want)
+		}
+	}
+}
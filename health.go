@@ -0,0 +1,51 @@
+package rcswitch
+
+import (
+	"fmt"
+	"time"
+)
+
+// PinHealth describes how well transmitting on the pin went so far.
+// A daemon can use it to mark a transmitter as degraded, e.g., if
+// ConsecutiveFailures is not 0.
+type PinHealth struct {
+	Transmissions       int // number of transmissions, successful or not
+	Failures            int // number of transmissions that failed
+	ConsecutiveFailures int // number of failed transmissions since the last successful one
+	LastError           error
+	LastErrorTime       time.Time
+}
+
+// Returns true if the last transmission was successful (or there was none yet).
+func (h PinHealth) OK() bool {
+	return h.ConsecutiveFailures == 0
+}
+
+func (h PinHealth) String() string {
+	if h.LastError == nil {
+		return fmt.Sprintf("%d transmissions, no failures", h.Transmissions)
+	}
+	return fmt.Sprintf("%d transmissions, %d failed (%d consecutive), last error at %s: %v",
+		h.Transmissions, h.Failures, h.ConsecutiveFailures, h.LastErrorTime.Format(time.RFC3339), h.LastError)
+}
+
+func (h *PinHealth) record(err error) {
+	h.Transmissions++
+	if err == nil {
+		h.ConsecutiveFailures = 0
+		return
+	}
+	h.Failures++
+	h.ConsecutiveFailures++
+	h.LastError = err
+	h.LastErrorTime = time.Now()
+}
+
+// Returns the health of the pin, i.e., statistics about failed pin.Out calls.
+// A transmission fails as soon as setting the pin fails, the error is returned
+// by the method that caused the transmission.
+func (s *RCSwitch) PinHealth() PinHealth {
+	s.Lock()
+	defer s.Unlock()
+	return s.health
+}
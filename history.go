@@ -0,0 +1,192 @@
+package rcswitch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// A Command is a single switch command as sent by SwitchOn and SwitchOff.
+// Format of Family, Group, and Device is the same as for SwitchOn.
+type Command struct {
+	Family, Group, Device string
+	On                    bool
+	Protocol              int     // 0 uses the protocol set by SetProtocol
+	Repeat                int     // 0 uses the repeat set by SetRepeat
+	Frequency             float64 // in MHz, 0 uses the frequency set by SetFrequency
+}
+
+// A HistoryEntry is a command of the history, see History.
+type HistoryEntry struct {
+	Command  Command
+	Time     time.Time // when the command was sent
+	CodeWord string    // tri-state code word
+	Protocol int       // protocol the command was sent with
+	Err      error     // nil if the command was sent successfully
+}
+
+type history struct {
+	size    int
+	entries []HistoryEntry // oldest first
+	file    *os.File       // optional, see SetHistoryFile
+}
+
+// JSON representation of a HistoryEntry in the history file.
+type historyRecord struct {
+	Time     time.Time `json:"time"`
+	Family   string    `json:"family,omitempty"`
+	Group    string    `json:"group"`
+	Device   string    `json:"device"`
+	On       bool      `json:"on"`
+	Protocol int       `json:"protocol"`
+	Repeat   int       `json:"repeat,omitempty"`
+	CodeWord string    `json:"code_word"`
+	Error    string    `json:"error,omitempty"`
+}
+
+func (h *history) add(e HistoryEntry) {
+	if h.size == 0 {
+		return
+	}
+	if len(h.entries) == h.size {
+		copy(h.entries, h.entries[1:])
+		h.entries = h.entries[:h.size-1]
+	}
+	h.entries = append(h.entries, e)
+}
+
+// Add a sent command to the history. Has to be called with s locked.
+func (s *RCSwitch) record(cmd Command, code string, protocol int, err error) {
+	e := HistoryEntry{Command: cmd, Time: time.Now(), CodeWord: code, Protocol: protocol, Err: err}
+	s.history.add(e)
+	if s.history.file == nil {
+		return
+	}
+	rec := historyRecord{
+		Time:     e.Time,
+		Family:   cmd.Family,
+		Group:    cmd.Group,
+		Device:   cmd.Device,
+		On:       cmd.On,
+		Protocol: protocol,
+		Repeat:   cmd.Repeat,
+		CodeWord: code,
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if b, err := json.Marshal(rec); err == nil {
+		s.history.file.Write(append(b, '\n')) // the log is best effort, sending must not fail because of it
+	}
+}
+
+// Append every command of the history to the file at path as a line of JSON,
+// as an audit log that survives restarts. The most recent commands of an
+// existing file are loaded into the history. An empty path stops writing.
+// The file is closed by Close.
+func (s *RCSwitch) SetHistoryFile(path string) error {
+	var f *os.File
+	var loaded []HistoryEntry
+	if path != "" {
+		var err error
+		if f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+			return err
+		}
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			var rec historyRecord
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				f.Close()
+				return fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+			e := HistoryEntry{
+				Command:  Command{Family: rec.Family, Group: rec.Group, Device: rec.Device, On: rec.On, Protocol: rec.Protocol, Repeat: rec.Repeat},
+				Time:     rec.Time,
+				CodeWord: rec.CodeWord,
+				Protocol: rec.Protocol,
+			}
+			if rec.Error != "" {
+				e.Err = errors.New(rec.Error)
+			}
+			loaded = append(loaded, e)
+		}
+		if err := scanner.Err(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	h := &s.history
+	if h.file != nil {
+		h.file.Close()
+	}
+	h.file = f
+	for _, e := range loaded {
+		h.add(e)
+	}
+	return nil
+}
+
+// Set the number of commands kept in the history.
+// The default is 10, 0 disables the history. Shrinking the history drops the oldest commands.
+func (s *RCSwitch) SetHistorySize(size int) error {
+	if size < 0 {
+		return errors.New("History size must not be negative")
+	}
+	s.Lock()
+	defer s.Unlock()
+	h := &s.history
+	if len(h.entries) > size {
+		h.entries = append([]HistoryEntry(nil), h.entries[len(h.entries)-size:]...)
+	}
+	h.size = size
+	return nil
+}
+
+// Returns the commands sent by SwitchOn, SwitchOff, Resend, Undo, and the
+// named devices (see On), including failed ones, newest first. Commands that
+// could not be sent at all, e.g., because of an invalid group, are not
+// included. The index of a command can be used for Resend.
+func (s *RCSwitch) History() []HistoryEntry {
+	s.Lock()
+	defer s.Unlock()
+	entries := s.history.entries
+	h := make([]HistoryEntry, len(entries))
+	for i, e := range entries {
+		h[len(entries)-1-i] = e
+	}
+	return h
+}
+
+// Send the n-th command of the history again, 0 is the most recent one.
+// This is handy if a frame was likely lost or the command failed.
+func (s *RCSwitch) Resend(n int) error {
+	s.Lock()
+	defer s.Unlock()
+	entries := s.history.entries
+	if n < 0 || n >= len(entries) {
+		return fmt.Errorf("There is no command %d in the history of %d commands", n, len(entries))
+	}
+	return s.switchTo(context.Background(), entries[len(entries)-1-n].Command)
+}
+
+// Undo the most recent successful command by sending the opposite state for the same switch.
+func (s *RCSwitch) Undo() error {
+	s.Lock()
+	defer s.Unlock()
+	entries := s.history.entries
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Err == nil {
+			cmd := entries[i].Command
+			cmd.On = !cmd.On
+			return s.switchTo(context.Background(), cmd)
+		}
+	}
+	return errors.New("History has no successful command, there is nothing to undo")
+}
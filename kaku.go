@@ -0,0 +1,137 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The family of self-learning dimmers for Dim.
+const KakuFamily = "kaku"
+
+// The self-learning KlikAanKlikUit / Intertechno protocol ("new KaKu").
+// A frame is a start pulse, 26 address bits, a group bit, an on/off bit, 4 unit
+// bits and, for dim commands, 4 level bits, followed by a stop pulse. Every bit
+// consists of two pulses, a 0 is 1:1 1:5, a 1 is 1:5 1:1. Dim commands send
+// the on/off bit as third state 1:1 1:1.
+var kakuProtocol = protocol{pulseLen: 260, repeat: 5}
+
+var (
+	kakuStart = waveform{1, 10}
+	kakuStop  = waveform{1, 40}
+	kakuZero  = []waveform{{1, 1}, {1, 5}}
+	kakuOne   = []waveform{{1, 5}, {1, 1}}
+	kakuDim   = []waveform{{1, 1}, {1, 1}}
+)
+
+// Turn on a self-learning KlikAanKlikUit / Intertechno switch.
+// address: 26 bit address (0 to 67108863), unit: 0-15.
+// To pair, put the switch into learning mode and call SwitchOnKaku with an
+// arbitrary address. The code filter (see SetCodeFilter) checks the binary
+// frame: 26 address bits, the group bit, the on/off bit, and 4 unit bits,
+// followed by 4 level bits for DimKaku, whose on/off bit counts as 0. The state is tracked with the decimal address as group and
+// the unit as device, e.g., IsOn("12345678", "3").
+func (s *RCSwitch) SwitchOnKaku(address uint32, unit int) error {
+	return s.sendKaku(address, unit, true, -1)
+}
+
+// Turn off a self-learning switch. Format is the same as for SwitchOnKaku.
+func (s *RCSwitch) SwitchOffKaku(address uint32, unit int) error {
+	return s.sendKaku(address, unit, false, -1)
+}
+
+// Set a self-learning dimmer to level (0-15). Format is the same as for SwitchOnKaku.
+func (s *RCSwitch) DimKaku(address uint32, unit, level int) error {
+	if level < 0 || level > 15 {
+		return fmt.Errorf("Dim level %d is not in the range of 0 to 15", level)
+	}
+	return s.sendKaku(address, unit, false, level)
+}
+
+// Set a dimmer to level (0-15) and track the level, see DimLevel.
+// family: KakuFamily, group: decimal 26 bit address (e.g., "12345678"),
+// device: unit 0-15 (e.g., "3"). Type A-D switches can not be dimmed.
+func (s *RCSwitch) Dim(family, group, device string, level int) error {
+	if family != KakuFamily {
+		return fmt.Errorf("Family %q does not support dimming, only %q does", family, KakuFamily)
+	}
+	address, err := strconv.ParseUint(group, 10, 32)
+	if err != nil {
+		return errors.New("Group of a dimmer has to be a decimal address")
+	}
+	unit, err := strconv.Atoi(device)
+	if err != nil {
+		return errors.New("Device of a dimmer has to be a decimal unit")
+	}
+	return s.DimKaku(uint32(address), unit, level)
+}
+
+// Returns the last dim level sent to a dimmer, ok is false if it was not dimmed yet.
+// Format is the same as for IsOn.
+func (s *RCSwitch) DimLevel(group, device string) (level int, ok bool) {
+	s.Lock()
+	defer s.Unlock()
+	level, ok = s.dimLevel[group+device]
+	return level, ok
+}
+
+// Send a KaKu command, level is only sent if it is not negative.
+func (s *RCSwitch) sendKaku(address uint32, unit int, on bool, level int) error {
+	if address >= 1<<26 {
+		return fmt.Errorf("Address %d is larger than 26 bit", address)
+	}
+	if unit < 0 || unit > 15 {
+		return fmt.Errorf("Unit %d is not in the range of 0 to 15", unit)
+	}
+
+	ws := []waveform{kakuStart}
+	var binary strings.Builder
+	bits := func(v uint32, n int) {
+		for i := n - 1; i >= 0; i-- {
+			if v&(1<<uint(i)) != 0 {
+				ws = append(ws, kakuOne...)
+				binary.WriteByte('1')
+			} else {
+				ws = append(ws, kakuZero...)
+				binary.WriteByte('0')
+			}
+		}
+	}
+	bits(address, 26)
+	bits(0, 1) // no group command
+	switch {
+	case level >= 0:
+		ws = append(ws, kakuDim...)
+		binary.WriteByte('0')
+	case on:
+		bits(1, 1)
+	default:
+		bits(0, 1)
+	}
+	bits(uint32(unit), 4)
+	if level >= 0 {
+		bits(uint32(level), 4)
+	}
+	ws = append(ws, kakuStop)
+
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkSend(binary.String()); err != nil {
+		return err
+	}
+	if err := s.transmit(context.Background(), ws, kakuProtocol, s.repeat(kakuProtocol)); err != nil {
+		return err
+	}
+
+	group, device := strconv.FormatUint(uint64(address), 10), strconv.Itoa(unit)
+	s.setState(group, device, on || level >= 0)
+	if level >= 0 && !s.stateless {
+		if s.dimLevel == nil {
+			s.dimLevel = make(map[string]int)
+		}
+		s.dimLevel[group+device] = level
+	}
+	return nil
+}
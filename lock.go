@@ -0,0 +1,47 @@
+package rcswitch
+
+import "os"
+
+// A lock file shared with other processes transmitting on the same pin.
+type lockFile struct {
+	f *os.File
+}
+
+// Serialize transmissions with other processes (e.g., cron jobs and a daemon)
+// using the same transmitter. Every transmission takes an exclusive lock on the
+// file at path (e.g., "/run/lock/rcswitch-gpio17"), which is created if it does
+// not exist. All processes sharing the transmitter have to use the same path.
+// Pair and StartContinuous lock the file for every single frame.
+// An empty path disables locking, which is the default. Locking uses flock,
+// it is supported on Linux, macOS, and the BSDs.
+func (s *RCSwitch) SetLockFile(path string) error {
+	var l *lockFile
+	if path != "" {
+		var err error
+		if l, err = openLockFile(path); err != nil {
+			return err
+		}
+	}
+
+	s.Lock()
+	old := s.lockFile
+	s.lockFile = l
+	s.Unlock()
+
+	if old != nil {
+		return old.f.Close()
+	}
+	return nil
+}
+
+// Run f while holding l, a nil l runs f without locking.
+func (l *lockFile) do(f func() error) error {
+	if l == nil {
+		return f()
+	}
+	if err := l.lock(); err != nil {
+		return err
+	}
+	defer l.unlock()
+	return f()
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package rcswitch
+
+import "errors"
+
+func openLockFile(path string) (*lockFile, error) {
+	return nil, errors.New("Lock files are not supported on this platform")
+}
+
+func (l *lockFile) lock() error {
+	return nil
+}
+
+func (l *lockFile) unlock() error {
+	return nil
+}
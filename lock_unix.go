@@ -0,0 +1,25 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package rcswitch
+
+import (
+	"os"
+	"syscall"
+)
+
+func openLockFile(path string) (*lockFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &lockFile{f: f}, nil
+}
+
+func (l *lockFile) lock() error {
+	return syscall.Flock(int(l.f.Fd()), syscall.LOCK_EX)
+}
+
+func (l *lockFile) unlock() error {
+	return syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,125 @@
+package rcswitch
+
+import "time"
+
+// SendResult is the outcome of a transmission.
+type SendResult int
+
+const (
+	SendOK       SendResult = iota // transmitted
+	SendFailed                     // setting the pin failed, see PinHealth
+	SendRejected                   // not transmitted, e.g., rejected by the code filter
+	SendAborted                    // aborted because its context was done
+)
+
+func (r SendResult) String() string {
+	switch r {
+	case SendOK:
+		return "ok"
+	case SendFailed:
+		return "failed"
+	case SendRejected:
+		return "rejected"
+	case SendAborted:
+		return "aborted"
+	}
+	return "unknown"
+}
+
+// MetricsSink gets notified about every transmission, so applications can feed
+// their own metrics system (e.g., to build histograms of the air time).
+// ObserveSend is called with the RCSwitch object locked, so it must not call
+// methods of the RCSwitch object and should return quickly.
+type MetricsSink interface {
+	ObserveSend(result SendResult, airTime time.Duration)
+}
+
+// CommandSink can additionally be implemented by a MetricsSink to be notified
+// about every command sent by SwitchOn and SwitchOff (including queued ones),
+// e.g., to count transmissions per switch. err is nil on success. The same
+// restrictions as for ObserveSend apply.
+type CommandSink interface {
+	ObserveCommand(cmd Command, err error)
+}
+
+// QueueSink can additionally be implemented by a MetricsSink to be notified
+// about the time every queued command (see EnqueueOn) waited in the transmit
+// queue before it was sent. The same restrictions as for ObserveSend apply.
+type QueueSink interface {
+	ObserveQueueWait(wait time.Duration)
+}
+
+// Metrics is a snapshot of the transmission statistics of an RCSwitch object.
+type Metrics struct {
+	Sends        map[SendResult]int // number of transmissions by result
+	AirTime      time.Duration      // total time spent transmitting
+	MaxAirTime   time.Duration      // longest single transmission
+	Dequeued     int                // number of queued commands taken from the transmit queue
+	QueueWait    time.Duration      // total time queued commands waited
+	MaxQueueWait time.Duration      // longest wait of a queued command
+}
+
+type metrics struct {
+	sends        [SendAborted + 1]int
+	airTime      time.Duration
+	maxAirTime   time.Duration
+	dequeued     int
+	queueWait    time.Duration
+	maxQueueWait time.Duration
+	sink         MetricsSink
+}
+
+func (m *metrics) observe(result SendResult, airTime time.Duration) {
+	m.sends[result]++
+	m.airTime += airTime
+	if airTime > m.maxAirTime {
+		m.maxAirTime = airTime
+	}
+	if m.sink != nil {
+		m.sink.ObserveSend(result, airTime)
+	}
+}
+
+// Set a sink that is notified about every transmission, nil removes it.
+// If sink implements CommandSink or QueueSink, it is notified about every
+// command or queue wait, too.
+func (s *RCSwitch) SetMetricsSink(sink MetricsSink) {
+	s.Lock()
+	s.metrics.sink = sink
+	s.Unlock()
+}
+
+// Returns a snapshot of the transmission statistics.
+func (s *RCSwitch) Metrics() Metrics {
+	s.Lock()
+	defer s.Unlock()
+	m := Metrics{
+		Sends:        make(map[SendResult]int, len(s.metrics.sends)),
+		AirTime:      s.metrics.airTime,
+		MaxAirTime:   s.metrics.maxAirTime,
+		Dequeued:     s.metrics.dequeued,
+		QueueWait:    s.metrics.queueWait,
+		MaxQueueWait: s.metrics.maxQueueWait,
+	}
+	for r, n := range s.metrics.sends {
+		m.Sends[SendResult(r)] = n
+	}
+	return m
+}
+
+func (m *metrics) observeCommand(cmd Command, err error) {
+	if c, ok := m.sink.(CommandSink); ok {
+		c.ObserveCommand(cmd, err)
+	}
+}
+
+func (m *metrics) observeQueueWait(wait time.Duration) {
+	m.dequeued++
+	m.queueWait += wait
+	if wait > m.maxQueueWait {
+		m.maxQueueWait = wait
+	}
+	if q, ok := m.sink.(QueueSink); ok {
+		q.ObserveQueueWait(wait)
+	}
+}
@@ -0,0 +1,85 @@
+package rcswitch
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Codes of the same switch and state received within this time are one button press.
+const mirrorRepeat = time.Second
+
+// Keep the tracked states (see IsOn) in sync with physical remotes: codes
+// received by rx are matched against the code words of the devices of the
+// registry (see SetDeviceRegistry) and of the switches defined by SetCodeWords,
+// and a match sets the state of the switch as if it had been switched by this
+// object, including calling the OnStateChange callbacks. Received codes that
+// match the tracked state, e.g., the own transmissions, are ignored. Devices of
+// the registry with a protocol only match codes of that protocol.
+// Mirror blocks until ctx is done or the RCSwitch object is closed, so it is
+// usually run in its own goroutine. Codes received by rx are still delivered
+// by its Codes.
+func (s *RCSwitch) Mirror(ctx context.Context, rx *Receiver) error {
+	codes, cancel := rx.listen()
+	defer cancel()
+
+	var last Command
+	var lastTime time.Time
+	for {
+		var c ReceivedCode
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.done:
+			return ErrClosed
+		case c = <-codes:
+		}
+
+		s.Lock()
+		cmd, ok := s.match(c)
+		if ok && !(cmd == last && time.Since(lastTime) < mirrorRepeat) &&
+			(s.stateless || s.isOn[cmd.Group+cmd.Device] != cmd.On) {
+			s.setState(cmd.Group, cmd.Device, cmd.On)
+		}
+		s.Unlock()
+		if ok {
+			last, lastTime = cmd, time.Now()
+		}
+	}
+}
+
+// Returns the switch and state c is the code word of. Has to be called with s locked.
+func (s *RCSwitch) match(c ReceivedCode) (Command, bool) {
+	is := func(code string) bool {
+		binary := triStateToBinary(code)
+		v, err := strconv.ParseUint(binary, 2, 64)
+		return err == nil && len(binary) == c.BitLength && v == c.Value
+	}
+
+	for sw, cw := range s.custom {
+		for _, on := range []bool{true, false} {
+			code := cw.off
+			if on {
+				code = cw.on
+			}
+			if is(code) {
+				return Command{Family: sw.Family, Group: sw.Group, Device: sw.Device, On: on}, true
+			}
+		}
+	}
+
+	if s.registry == nil {
+		return Command{}, false
+	}
+	for _, d := range s.registry.Devices() {
+		if d.Protocol != 0 && d.Protocol != c.Protocol {
+			continue
+		}
+		for _, on := range []bool{true, false} {
+			if code, err := s.codeWord(d.Family, d.Group, d.Device, on); err == nil && is(code) {
+				return d.command(on), true
+			}
+		}
+	}
+	return Command{}, false
+}
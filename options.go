@@ -0,0 +1,28 @@
+package rcswitch
+
+// An Option overrides a setting of the RCSwitch object for a single call of
+// SwitchOn or SwitchOff.
+type Option func(*Command)
+
+// Send with the given protocol instead of the one set by SetProtocol.
+func WithProtocol(protocol int) Option {
+	return func(c *Command) { c.Protocol = protocol }
+}
+
+// Send nrRepeat frames instead of the number set by SetRepeat.
+func WithRepeat(nrRepeat int) Option {
+	return func(c *Command) { c.Repeat = nrRepeat }
+}
+
+// Send on the given frequency in MHz instead of the one set by SetFrequency.
+func WithFrequency(mhz float64) Option {
+	return func(c *Command) { c.Frequency = mhz }
+}
+
+func newCommand(family, group, device string, on bool, opts []Option) Command {
+	cmd := Command{Family: family, Group: group, Device: device, On: on}
+	for _, opt := range opts {
+		opt(&cmd)
+	}
+	return cmd
+}
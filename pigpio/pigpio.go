@@ -0,0 +1,190 @@
+// Package pigpio provides an rcswitch.Transmitter that lets the pigpio daemon
+// (pigpiod) send pulse trains as DMA timed waveforms. Timing is done in
+// hardware, so transmissions are not corrupted by scheduler jitter on busy
+// systems. pigpiod has to be running, e.g., "sudo pigpiod".
+package pigpio
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rck/rcswitch"
+)
+
+// pigpiod socket commands, see pigpio.h.
+const (
+	cmdModes = 0
+	cmdWrite = 4
+	cmdWvclr = 27
+	cmdWvag  = 28
+	cmdWvbsy = 32
+	cmdWvhlt = 33
+	cmdWvcre = 49
+	cmdWvdel = 50
+	cmdWvcha = 93
+)
+
+const modeOutput = 1
+
+// How often the daemon is asked whether a transmission is done.
+const pollInterval = time.Millisecond
+
+// Transmitter sends transmissions via pigpiod.
+type Transmitter struct {
+	conn net.Conn
+	gpio uint32
+	sync.Mutex
+}
+
+// Connect to pigpiod at addr (e.g., "localhost:8888") and create a Transmitter
+// for the given Broadcom GPIO number. The GPIO is set to output.
+func New(addr string, gpio int) (*Transmitter, error) {
+	if gpio < 0 || gpio > 31 {
+		return nil, fmt.Errorf("GPIO %d is not in the range of 0 to 31", gpio)
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	t := &Transmitter{conn: conn, gpio: uint32(gpio)}
+	if _, err := t.command(cmdModes, t.gpio, modeOutput, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// Close drives the GPIO low and closes the connection to pigpiod.
+func (t *Transmitter) Close() error {
+	t.Lock()
+	defer t.Unlock()
+	_, err := t.command(cmdWrite, t.gpio, 0, nil)
+	if cerr := t.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Transmit implements rcswitch.Transmitter.
+// The preamble and the frame are created as waveforms, the frame is repeated by
+// a wave chain, so there are no gaps between repeats. The GPIO is low afterwards.
+func (t *Transmitter) Transmit(ctx context.Context, tr rcswitch.Transmission) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if tr.Repeat > 0xffff {
+		return fmt.Errorf("Repeat %d is larger than the maximum of %d", tr.Repeat, 0xffff)
+	}
+
+	if _, err := t.command(cmdWvclr, 0, 0, nil); err != nil {
+		return err
+	}
+
+	var chain []byte
+	if tr.Preamble > 0 {
+		var pre []rcswitch.Waveform
+		for i := 0; i < tr.Preamble; i++ {
+			pre = append(pre, rcswitch.Waveform{High: 1, Low: 1})
+		}
+		id, err := t.createWave(pre, tr, 0)
+		if err != nil {
+			return err
+		}
+		defer t.command(cmdWvdel, id, 0, nil)
+		chain = append(chain, byte(id))
+	}
+
+	// The gap is added after every frame, the one after the last frame does not hurt.
+	id, err := t.createWave(tr.Frame, tr, tr.Gap)
+	if err != nil {
+		return err
+	}
+	defer t.command(cmdWvdel, id, 0, nil)
+	// loop start, wave, loop end with repeat count
+	chain = append(chain, 255, 0, byte(id), 255, 1, byte(tr.Repeat), byte(tr.Repeat>>8))
+
+	if _, err := t.command(cmdWvcha, 0, 0, chain); err != nil {
+		return err
+	}
+
+	for {
+		busy, err := t.command(cmdWvbsy, 0, 0, nil)
+		if err != nil {
+			return err
+		}
+		if busy == 0 {
+			// Inverted frames end high, the transmitter must not stay keyed up.
+			_, err := t.command(cmdWrite, t.gpio, 0, nil)
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			t.command(cmdWvhlt, 0, 0, nil)
+			t.command(cmdWrite, t.gpio, 0, nil)
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Add the pulses of ws followed by gap as generic waveform and create it,
+// returns the wave id.
+func (t *Transmitter) createWave(ws []rcswitch.Waveform, tr rcswitch.Transmission, gap time.Duration) (uint32, error) {
+	high, low := uint32(1)<<t.gpio, uint32(0)
+	if tr.Inverted {
+		high, low = low, high
+	}
+	us := uint32(tr.PulseLength / time.Microsecond)
+
+	// gpioPulse_t: gpioOn, gpioOff, usDelay
+	pulses := make([]byte, 0, len(ws)*2*12)
+	pulse := func(on, off, delay uint32) {
+		var p [12]byte
+		binary.LittleEndian.PutUint32(p[0:], on)
+		binary.LittleEndian.PutUint32(p[4:], off)
+		binary.LittleEndian.PutUint32(p[8:], delay)
+		pulses = append(pulses, p[:]...)
+	}
+	for _, w := range ws {
+		pulse(high, low, uint32(w.High)*us)
+		pulse(low, high, uint32(w.Low)*us)
+	}
+	if gap > 0 { // carrier off, also after inverted frames
+		pulse(0, uint32(1)<<t.gpio, uint32(gap/time.Microsecond))
+	}
+
+	if _, err := t.command(cmdWvag, 0, 0, pulses); err != nil {
+		return 0, err
+	}
+	return t.command(cmdWvcre, 0, 0, nil)
+}
+
+// Send a command to pigpiod and return its result.
+// Every command is 16 bytes (cmd, p1, p2, p3) followed by p3 bytes of extension,
+// the response echoes cmd, p1, p2 followed by the result.
+func (t *Transmitter) command(cmd, p1, p2 uint32, ext []byte) (uint32, error) {
+	req := make([]byte, 16, 16+len(ext))
+	binary.LittleEndian.PutUint32(req[0:], cmd)
+	binary.LittleEndian.PutUint32(req[4:], p1)
+	binary.LittleEndian.PutUint32(req[8:], p2)
+	binary.LittleEndian.PutUint32(req[12:], uint32(len(ext)))
+	req = append(req, ext...)
+	if _, err := t.conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	var resp [16]byte
+	if _, err := io.ReadFull(t.conn, resp[:]); err != nil {
+		return 0, err
+	}
+	res := int32(binary.LittleEndian.Uint32(resp[12:]))
+	if res < 0 {
+		return 0, fmt.Errorf("pigpiod command %d failed with error %d", cmd, res)
+	}
+	return uint32(res), nil
+}
@@ -0,0 +1,75 @@
+package rcswitch
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// Number of samples taken by ProbeTiming.
+const probeSamples = 100
+
+// Maximum timing error relative to the pulse length that receivers usually cope with.
+const probeMaxError = 0.3
+
+// TimingProbe is the result of ProbeTiming.
+type TimingProbe struct {
+	PulseLength    time.Duration // pulse length of the current protocol
+	OutLatency     time.Duration // average time a pin.Out call takes
+	SleepOvershoot time.Duration // average time waiting for one pulse length takes longer, in the timing mode of the GPIO transmitter
+}
+
+// Returns an error describing the problem if the platform cannot plausibly
+// meet the pulse length, i.e., if the timing error of a single pulse is larger
+// than 30% of the pulse length. Returns nil otherwise.
+func (p TimingProbe) Warning() error {
+	if e := p.OutLatency + p.SleepOvershoot; float64(e) > probeMaxError*float64(p.PulseLength) {
+		return fmt.Errorf("Timing error of %s per pulse (pin.Out %s, wait overshoot %s) is too large for a pulse length of %s, transmissions will likely fail",
+			e, p.OutLatency, p.SleepOvershoot, p.PulseLength)
+	}
+	return nil
+}
+
+// Measure how long setting the pin takes and how precise waiting for one
+// pulse length of the current protocol is, with the timing mode set by
+// SetTimingMode. Call it once after setting pin and
+// protocol, and check the Warning of the result. This catches setups that are
+// doomed to fail (e.g., protocol 3 with its 100µs pulses over slow sysfs GPIO)
+// early. The pin is kept idle while probing, so nothing is transmitted.
+// Probing takes about 100 pulse lengths.
+func (s *RCSwitch) ProbeTiming() (TimingProbe, error) {
+	s.Lock()
+	defer s.Unlock()
+	p := TimingProbe{PulseLength: s.protocol.pulseLen * time.Microsecond}
+	if s.pin == nil {
+		return p, errors.New("Timing can only be probed for GPIO pins")
+	}
+
+	idle := gpio.Low
+	if s.activeLow {
+		idle = gpio.High
+	}
+	start := time.Now()
+	for i := 0; i < probeSamples; i++ {
+		if err := s.pin.Out(idle); err != nil {
+			return p, err
+		}
+	}
+	p.OutLatency = time.Since(start) / probeSamples
+
+	var overshoot time.Duration
+	for i := 0; i < probeSamples; i++ {
+		start := time.Now()
+		if s.timing == Precise {
+			WaitUntil(start.Add(p.PulseLength))
+		} else {
+			time.Sleep(p.PulseLength)
+		}
+		overshoot += time.Since(start) - p.PulseLength
+	}
+	p.SleepOvershoot = overshoot / probeSamples
+
+	return p, nil
+}
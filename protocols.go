@@ -0,0 +1,72 @@
+package rcswitch
+
+import "time"
+
+// Waveform of a bit or of the sync word in multiples of the pulse length.
+type Waveform struct {
+	High, Low int
+}
+
+// ProtocolInfo describes one of the supported transmission protocols.
+type ProtocolInfo struct {
+	Number      int // as used by SetProtocol
+	PulseLength time.Duration
+	Sync        Waveform
+	Zero        Waveform
+	One         Waveform
+	Inverted    bool // high and low are swapped on the air
+	Preamble    int  // number of 1:1 wake-up pulses before the first frame
+	SyncRepeat  int  // number of sync bits per frame
+	Repeat      int  // default number of frames per transmission
+	LSBFirst    bool // numeric codes are sent least significant bit first
+}
+
+// Returns all protocols supported by SetProtocol.
+func Protocols() []ProtocolInfo {
+	infos := make([]ProtocolInfo, len(protocols))
+	for i, p := range protocols {
+		infos[i] = ProtocolInfo{
+			Number:      i + 1,
+			PulseLength: p.pulseLen * time.Microsecond,
+			Sync:        exportWaveform(p.syncBit),
+			Zero:        exportWaveform(p.zeroBit),
+			One:         exportWaveform(p.oneBit),
+			Inverted:    p.inverted,
+			Preamble:    p.preamble,
+			SyncRepeat:  p.syncRepeat,
+			Repeat:      p.repeat,
+			LSBFirst:    p.lsbFirst,
+		}
+		if infos[i].SyncRepeat == 0 {
+			infos[i].SyncRepeat = 1
+		}
+	}
+	return infos
+}
+
+func exportWaveform(w waveform) Waveform {
+	return Waveform{High: w.high, Low: w.low}
+}
+
+// SocketType describes the family, group, and device arguments accepted by
+// SwitchOn and SwitchOff for one type of socket.
+// Empty strings for Family, Group, or Device mean the argument is unused and has to be "".
+type SocketType struct {
+	Name       string // A, B, C, or D (REV Telecontrol)
+	Family     string // accepted values in human readable form
+	Group      string
+	Device     string
+	CodeLength int  // length of the resulting tri-state code word
+	Tested     bool // verified with real hardware
+	Dimming    bool
+}
+
+// Returns all socket types supported by SwitchOn and SwitchOff.
+func SocketTypes() []SocketType {
+	return []SocketType{
+		{Name: "A", Group: "5 binary digits (e.g., 11011)", Device: "5 binary digits (e.g., 10000)", CodeLength: 12, Tested: true},
+		{Name: "B", Group: "1-4", Device: "1-4", CodeLength: 12},
+		{Name: "C", Family: "a-p", Group: "1-4", Device: "1-4", CodeLength: 12},
+		{Name: "D", Group: "a-d", Device: "1-3", CodeLength: 12},
+	}
+}
@@ -0,0 +1,198 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by EnqueueOn and EnqueueOff if the transmit queue
+// is full and the queue is not blocking, see SetQueueLimit.
+var ErrQueueFull = errors.New("Transmit queue is full")
+
+// A command waiting in the transmit queue.
+type queuedCommand struct {
+	Command
+	queued time.Time
+}
+
+// The transmit queue served by a worker goroutine.
+type transmitQueue struct {
+	commands []queuedCommand
+	busy     bool  // the worker is sending a command
+	limit    int   // maximum number of queued commands
+	block    bool  // block instead of returning ErrQueueFull
+	closed   bool  // no new commands are accepted
+	running  bool  // the worker goroutine was started
+	err      error // first error since the last Flush
+	changed  chan struct{}
+	sync.Mutex
+}
+
+// Wake up everyone waiting for a change of the queue. Has to be called with q locked.
+func (q *transmitQueue) broadcast() {
+	if q.changed != nil {
+		close(q.changed)
+	}
+	q.changed = make(chan struct{})
+}
+
+// Wait until done returns true. Has to be called with q locked, q is locked
+// again when wait returns.
+func (q *transmitQueue) wait(ctx context.Context, done func() bool) error {
+	for !done() {
+		if q.changed == nil {
+			q.changed = make(chan struct{})
+		}
+		changed := q.changed
+		q.Unlock()
+		select {
+		case <-changed:
+			q.Lock()
+		case <-ctx.Done():
+			q.Lock()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Set the back-pressure of the transmit queue used by EnqueueOn and EnqueueOff.
+// At most limit commands are queued, the default is 64. If the queue is full,
+// enqueueing blocks until there is space if block is set (the default), or
+// fails with ErrQueueFull otherwise.
+func (s *RCSwitch) SetQueueLimit(limit int, block bool) error {
+	if limit <= 0 {
+		return errors.New("Queue limit has to be a positive number")
+	}
+	q := &s.queue
+	q.Lock()
+	q.limit, q.block = limit, block
+	q.broadcast()
+	q.Unlock()
+	return nil
+}
+
+// Queue turning on a switch and return immediately.
+// Format is the same as for SwitchOn. Queued commands are sent one after the
+// other by a worker goroutine. If the last command waiting in the queue for the
+// same switch is identical, the command is not queued again. The code word is
+// checked right away, errors of the transmission are returned by Flush.
+func (s *RCSwitch) EnqueueOn(family, group, device string) error {
+	return s.enqueue(Command{Family: family, Group: group, Device: device, On: true})
+}
+
+// Queue turning off a switch, see EnqueueOn.
+func (s *RCSwitch) EnqueueOff(family, group, device string) error {
+	return s.enqueue(Command{Family: family, Group: group, Device: device, On: false})
+}
+
+func (s *RCSwitch) enqueue(cmd Command) error {
+	s.Lock()
+	_, err := s.codeWord(cmd.Family, cmd.Group, cmd.Device, cmd.On)
+	s.Unlock()
+	if err != nil {
+		return err
+	}
+
+	q := &s.queue
+	q.Lock()
+	defer q.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	for i := len(q.commands) - 1; i >= 0; i-- {
+		c := q.commands[i]
+		if c.Family == cmd.Family && c.Group == cmd.Group && c.Device == cmd.Device {
+			if c.On == cmd.On { // queuing it again would not change anything
+				return nil
+			}
+			break
+		}
+	}
+	if len(q.commands) >= q.limit {
+		if !q.block {
+			return ErrQueueFull
+		}
+		q.wait(context.Background(), func() bool { return q.closed || len(q.commands) < q.limit })
+		if q.closed {
+			return ErrClosed
+		}
+	}
+
+	q.commands = append(q.commands, queuedCommand{Command: cmd, queued: time.Now()})
+	if !q.running {
+		q.running = true
+		go s.work()
+	}
+	q.broadcast()
+	return nil
+}
+
+// Returns the number of commands waiting in the transmit queue.
+func (s *RCSwitch) QueueLen() int {
+	s.queue.Lock()
+	defer s.queue.Unlock()
+	return len(s.queue.commands)
+}
+
+// Wait until all queued commands are sent, or ctx is done.
+// Returns the first error of a queued command since the last Flush, or ctx.Err().
+func (s *RCSwitch) Flush(ctx context.Context) error {
+	q := &s.queue
+	q.Lock()
+	defer q.Unlock()
+	if err := q.wait(ctx, func() bool { return len(q.commands) == 0 && !q.busy }); err != nil {
+		return err
+	}
+	err := q.err
+	q.err = nil
+	return err
+}
+
+// Context key marking the commands of the queue worker, which are still sent
+// while Close drains the queue.
+type queuedKey struct{}
+
+// The worker sending queued commands.
+func (s *RCSwitch) work() {
+	q := &s.queue
+	q.Lock()
+	defer q.Unlock()
+	for {
+		q.wait(context.Background(), func() bool { return len(q.commands) > 0 || q.closed })
+		if len(q.commands) == 0 { // closed and drained
+			q.running = false
+			return
+		}
+
+		c := q.commands[0]
+		q.commands = q.commands[1:]
+		q.busy = true
+		q.broadcast()
+		q.Unlock()
+
+		s.Lock()
+		s.metrics.observeQueueWait(time.Since(c.queued))
+		s.Unlock()
+		err := s.switchCoalesced(context.WithValue(context.Background(), queuedKey{}, true), c.Command)
+
+		q.Lock()
+		q.busy = false
+		if err != nil && q.err == nil {
+			q.err = err
+		}
+		q.broadcast()
+	}
+}
+
+// Stop accepting queued commands and wait until the queue is drained or ctx is done.
+func (s *RCSwitch) closeQueue(ctx context.Context) error {
+	q := &s.queue
+	q.Lock()
+	defer q.Unlock()
+	q.closed = true
+	q.broadcast()
+	return q.wait(ctx, func() bool { return len(q.commands) == 0 && !q.busy })
+}
@@ -0,0 +1,127 @@
+// Package rcprom exports the transmission statistics of an rcswitch.RCSwitch
+// object as Prometheus metrics.
+//
+//	prometheus.MustRegister(rcprom.New(rc))
+//	prometheus.MustRegister(rcprom.NewReceiverCollector(receiver)) // optional
+//	http.Handle("/metrics", promhttp.Handler())
+package rcprom
+
+import (
+	"time"
+
+	"github.com/rck/rcswitch"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "rcswitch"
+
+// Collector is a prometheus.Collector for an RCSwitch object.
+type Collector struct {
+	rc        *rcswitch.RCSwitch
+	sends     *prometheus.Desc
+	queue     *prometheus.Desc
+	airTime   prometheus.Histogram
+	queueWait prometheus.Histogram
+	commands  *prometheus.CounterVec
+}
+
+// Create a Collector for rc. The Collector is set as metrics sink of rc (see
+// SetMetricsSink), replacing an existing one.
+func New(rc *rcswitch.RCSwitch) *Collector {
+	c := &Collector{
+		rc: rc,
+		sends: prometheus.NewDesc(namespace+"_transmissions_total",
+			"Number of transmissions by result.", []string{"result"}, nil),
+		queue: prometheus.NewDesc(namespace+"_queue_length",
+			"Number of commands waiting in the transmit queue.", nil, nil),
+		airTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "transmission_duration_seconds",
+			Help:      "Duration of transmissions, including failed and aborted ones.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 10), // 10ms to ~5s
+		}),
+		queueWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "queue_wait_seconds",
+			Help:      "Time queued commands waited in the transmit queue before they were sent.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12), // 10ms to ~20s
+		}),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commands_total",
+			Help:      "Number of switch commands by switch, state, and result.",
+		}, []string{"family", "group", "device", "state", "result"}),
+	}
+	rc.SetMetricsSink(c)
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.sends
+	ch <- c.queue
+	c.airTime.Describe(ch)
+	c.queueWait.Describe(ch)
+	c.commands.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for result, n := range c.rc.Metrics().Sends {
+		ch <- prometheus.MustNewConstMetric(c.sends, prometheus.CounterValue, float64(n), result.String())
+	}
+	ch <- prometheus.MustNewConstMetric(c.queue, prometheus.GaugeValue, float64(c.rc.QueueLen()))
+	c.airTime.Collect(ch)
+	c.queueWait.Collect(ch)
+	c.commands.Collect(ch)
+}
+
+// ObserveSend implements rcswitch.MetricsSink.
+func (c *Collector) ObserveSend(result rcswitch.SendResult, airTime time.Duration) {
+	if result != rcswitch.SendRejected { // nothing was transmitted
+		c.airTime.Observe(airTime.Seconds())
+	}
+}
+
+// ObserveQueueWait implements rcswitch.QueueSink.
+func (c *Collector) ObserveQueueWait(wait time.Duration) {
+	c.queueWait.Observe(wait.Seconds())
+}
+
+// ObserveCommand implements rcswitch.CommandSink.
+func (c *Collector) ObserveCommand(cmd rcswitch.Command, err error) {
+	state, result := "off", "ok"
+	if cmd.On {
+		state = "on"
+	}
+	if err != nil {
+		result = "error"
+	}
+	c.commands.WithLabelValues(cmd.Family, cmd.Group, cmd.Device, state, result).Inc()
+}
+
+// ReceiverCollector is a prometheus.Collector for a Receiver object.
+type ReceiverCollector struct {
+	r       *rcswitch.Receiver
+	decoded *prometheus.Desc
+}
+
+// Create a ReceiverCollector for r.
+func NewReceiverCollector(r *rcswitch.Receiver) *ReceiverCollector {
+	return &ReceiverCollector{
+		r: r,
+		decoded: prometheus.NewDesc(namespace+"_decoded_codes_total",
+			"Number of codes decoded by the receiver, including filtered ones.", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *ReceiverCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.decoded
+}
+
+// Collect implements prometheus.Collector.
+func (c *ReceiverCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.decoded, prometheus.CounterValue, float64(c.r.Decoded()))
+}
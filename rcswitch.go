@@ -3,14 +3,19 @@
 package rcswitch
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
 )
 
 type waveform struct {
@@ -21,36 +26,98 @@ type protocol struct {
 	pulseLen                 time.Duration
 	syncBit, zeroBit, oneBit waveform
 	inverted                 bool
+	preamble                 int              // number of 1:1 wake-up pulses sent before the first frame
+	syncRepeat               int              // number of sync bits per frame, 0 is the same as 1
+	repeat                   int              // default number of frames per transmission, see SetRepeat
+	lsbFirst                 bool             // numeric codes are sent least significant bit first
+	frequency                physic.Frequency // of a single command, see WithFrequency
 }
 
+// Used by SendRaw, waveforms are given in microseconds.
+var rawProtocol = protocol{pulseLen: 1, repeat: 1}
+
 var protocols = []protocol{
 	// protocol 1
-	{pulseLen: 350, syncBit: waveform{1, 31}, zeroBit: waveform{1, 3}, oneBit: waveform{3, 1}},
+	{pulseLen: 350, repeat: 10, syncBit: waveform{1, 31}, zeroBit: waveform{1, 3}, oneBit: waveform{3, 1}},
 	// protocol 2
-	{pulseLen: 650, syncBit: waveform{1, 10}, zeroBit: waveform{1, 2}, oneBit: waveform{2, 1}},
+	{pulseLen: 650, repeat: 10, syncBit: waveform{1, 10}, zeroBit: waveform{1, 2}, oneBit: waveform{2, 1}},
 	// protocol 3
-	{pulseLen: 100, syncBit: waveform{30, 71}, zeroBit: waveform{4, 11}, oneBit: waveform{9, 6}},
+	{pulseLen: 100, repeat: 10, syncBit: waveform{30, 71}, zeroBit: waveform{4, 11}, oneBit: waveform{9, 6}},
 	// protocol 4
-	{pulseLen: 380, syncBit: waveform{1, 6}, zeroBit: waveform{1, 3}, oneBit: waveform{3, 1}},
+	{pulseLen: 380, repeat: 10, syncBit: waveform{1, 6}, zeroBit: waveform{1, 3}, oneBit: waveform{3, 1}},
 	// protocol 5
-	{pulseLen: 500, syncBit: waveform{6, 14}, zeroBit: waveform{1, 2}, oneBit: waveform{2, 1}},
+	{pulseLen: 500, repeat: 10, syncBit: waveform{6, 14}, zeroBit: waveform{1, 2}, oneBit: waveform{2, 1}},
 	// protocol 6 (HT6P20B)
-	{pulseLen: 450, syncBit: waveform{23, 1}, zeroBit: waveform{1, 2}, oneBit: waveform{2, 1}, inverted: true},
+	{pulseLen: 450, repeat: 10, syncBit: waveform{23, 1}, zeroBit: waveform{1, 2}, oneBit: waveform{2, 1}, inverted: true},
+	// protocol 7 (HS2303-PT, e.g., AUKEY remotes)
+	{pulseLen: 150, repeat: 10, syncBit: waveform{2, 62}, zeroBit: waveform{1, 6}, oneBit: waveform{6, 1}},
+	// protocol 8 (Conrad RS-200 RX)
+	{pulseLen: 200, repeat: 10, syncBit: waveform{3, 130}, zeroBit: waveform{7, 16}, oneBit: waveform{3, 16}},
+	// protocol 9 (Conrad RS-200 TX)
+	{pulseLen: 200, repeat: 10, syncBit: waveform{130, 7}, zeroBit: waveform{16, 7}, oneBit: waveform{16, 3}, inverted: true},
+	// protocol 10 (1ByOne doorbell)
+	{pulseLen: 365, repeat: 10, syncBit: waveform{18, 1}, zeroBit: waveform{3, 1}, oneBit: waveform{1, 3}, inverted: true},
+	// protocol 11 (HT12E)
+	{pulseLen: 270, repeat: 10, syncBit: waveform{36, 1}, zeroBit: waveform{1, 2}, oneBit: waveform{2, 1}, inverted: true},
+	// protocol 12 (SM5212)
+	{pulseLen: 320, repeat: 10, syncBit: waveform{36, 1}, zeroBit: waveform{1, 2}, oneBit: waveform{2, 1}, inverted: true},
 }
 
 // The RCSwitch object.
 type RCSwitch struct {
-	pin      gpio.PinIO
-	protocol protocol
-	nrRepeat int
-	isOn     map[string]bool
+	pin         gpio.PinIO // nil if the transmitter is not a GPIO pin, see SetTransmitter
+	tx          Transmitter
+	timing      TimingMode // of the GPIO transmitter
+	activeLow   bool       // of the GPIO transmitter, see SetOutputInverted
+	protocol    protocol
+	protocolNr  int // as set by SetProtocol
+	nrRepeat    int // 0 uses the protocol's default
+	repeatGap   time.Duration
+	frequency   physic.Frequency // 0 is the frequency of the transmitter, see SetFrequency
+	isOn        map[string]bool
+	dimLevel    map[string]int                        // last level sent by Dim
+	commands    map[Switch]Command                    // last command per switch, see Refresh
+	stateless   bool                                  // do not track isOn, see SetStateTracking
+	stateChange []func(group, device string, on bool) // see OnStateChange
+	progress    []func(BatchProgress)                 // see OnBatchProgress
+	custom      map[Switch]codeWords
+	encoder     Encoder             // nil is AutoEncoder, see SetEncoder
+	autoOff     map[Switch]*autoOff // pending "off" commands of SwitchOnFor
+	autoOffFile string              // optional, see SetAutoOffFile
+	allowed     map[string]bool     // binary code words, empty allows everything
+	denied      map[string]bool     // binary code words
+	closed      int32               // accessed atomically, 1 while Close drains the queue, 2 afterwards
+	draining    bool                // the queue worker is sending while closing, see isClosed
+	done        chan struct{}       // closed by Close, stops background goroutines
+	history     history
+	health      PinHealth
+	metrics     metrics
+	lockFile    *lockFile // optional, see SetLockFile
+	pending     pendingCommands
+	queue       transmitQueue
+	duty        dutyCycle
+	registry    *DeviceRegistry // optional, see SetDeviceRegistry
+	verify      verification
+	lastReport  TransmissionReport
+	waveforms   map[waveformKey][]waveform // see waveform
+	dryRun      bool                       // see SetDryRun
 	sync.Mutex
 }
 
+// ErrCodeRejected is returned if a code word is not allowed to be sent.
+// See SetCodeFilter.
+var ErrCodeRejected = errors.New("Code word rejected by code filter")
+
+// ErrClosed is returned if a command is sent to a closed RCSwitch object.
+var ErrClosed = errors.New("RCSwitch is closed")
+
 // Create RCSwitch object for the given pin.
+// The pin may be nil if the object is only used in dry-run mode, see SetDryRun.
 func NewRCSwitch(pin gpio.PinIO) *RCSwitch {
 	s := RCSwitch{
-		nrRepeat: 10,
+		history: history{size: 10},
+		queue:   transmitQueue{limit: 64, block: true},
+		done:    make(chan struct{}),
 	}
 
 	s.isOn = make(map[string]bool)
@@ -60,17 +127,72 @@ func NewRCSwitch(pin gpio.PinIO) *RCSwitch {
 }
 
 // Set the pin of the RCSwitch object.
+// The pin is driven by bit-banging, see NewGPIOTransmitter.
+// This resets the pin health, see PinHealth.
 func (s *RCSwitch) SetPin(pin gpio.PinIO) {
+	s.Lock()
+	tx := &gpioTransmitter{pin: pin, mode: s.timing, activeLow: s.activeLow}
+	s.Unlock()
+	s.SetTransmitter(tx)
 	s.Lock()
 	s.pin = pin
 	s.Unlock()
 }
 
+// Set how the GPIO transmitter (see SetPin) waits between level changes.
+// The default is Sleep. Other transmitter backends have their own timing, for
+// them SetTimingMode returns an error.
+func (s *RCSwitch) SetTimingMode(mode TimingMode) error {
+	s.Lock()
+	defer s.Unlock()
+	t, ok := s.tx.(*gpioTransmitter)
+	if !ok {
+		return errors.New("Timing mode can only be set for the GPIO transmitter")
+	}
+	if mode == Precise {
+		CalibrateWaitUntil()
+	}
+	t.mode = mode
+	s.timing = mode
+	return nil
+}
+
+// Set whether the GPIO transmitter (see SetPin) drives active-low hardware,
+// e.g., a transmitter module behind an inverting driver transistor. High and
+// low are swapped on the pin, independent of the inverted flag of the
+// protocol, and the pin idles high. Other transmitter backends return an error.
+func (s *RCSwitch) SetOutputInverted(inverted bool) error {
+	s.Lock()
+	defer s.Unlock()
+	t, ok := s.tx.(*gpioTransmitter)
+	if !ok {
+		return errors.New("Output can only be inverted for the GPIO transmitter")
+	}
+	t.activeLow = inverted
+	s.activeLow = inverted
+	if s.pin != nil {
+		return s.pin.Out(t.idle())
+	}
+	return nil
+}
+
+// Set the transmitter backend of the RCSwitch object, e.g., to use a backend
+// with hardware timing instead of bit-banging a pin.
+// This resets the pin health, see PinHealth.
+func (s *RCSwitch) SetTransmitter(tx Transmitter) {
+	s.Lock()
+	s.tx = tx
+	s.pin = nil
+	s.health = PinHealth{}
+	s.Unlock()
+}
+
 // A wave form (e.g., for "on") is sent this number of times.
-// The default is 10.
+// The default depends on the protocol, it is 10 for all built-in protocols
+// (see ProtocolInfo.Repeat). Setting 0 restores the protocol's default.
 func (s *RCSwitch) SetRepeat(nrRepeat int) error {
-	if nrRepeat <= 0 {
-		return errors.New("Repeat has to be a positive number")
+	if nrRepeat < 0 {
+		return errors.New("Repeat must not be negative")
 	}
 	s.Lock()
 	s.nrRepeat = nrRepeat
@@ -86,43 +208,372 @@ func (s *RCSwitch) SetProtocol(protocol int) error {
 	}
 	s.Lock()
 	s.protocol = protocols[protocol-1]
+	s.protocolNr = protocol
+	s.Unlock()
+	return nil
+}
+
+// Set the pulse length of the current protocol, e.g., as shown by a sniffer
+// for sockets deviating from the protocol default. It has a resolution of one
+// microsecond and is reset by SetProtocol.
+func (s *RCSwitch) SetPulseLength(d time.Duration) error {
+	if d < time.Microsecond {
+		return fmt.Errorf("Pulse length %s is shorter than 1µs", d)
+	}
+	s.Lock()
+	s.protocol.pulseLen = d / time.Microsecond
+	s.Unlock()
+	return nil
+}
+
+// Insert an additional low period of d between repeated frames, for
+// receivers that need more silence than the sync bit provides. The default
+// is 0.
+func (s *RCSwitch) SetRepeatGap(d time.Duration) error {
+	if d < 0 {
+		return errors.New("Repeat gap must not be negative")
+	}
+	s.Lock()
+	s.repeatGap = d
 	s.Unlock()
 	return nil
 }
 
+// Set the carrier frequency in MHz, e.g., 315, 433.92, or 868.35, for
+// transmitter backends that can change it, like those of package spiradio.
+// Commands and devices can have their own frequency, see WithFrequency and
+// Device. Backends with a fixed frequency, like the GPIO one, ignore it. The
+// default is 0, which keeps the frequency the backend was set up with.
+func (s *RCSwitch) SetFrequency(mhz float64) error {
+	f, err := megaHertz(mhz)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	s.frequency = f
+	s.Unlock()
+	return nil
+}
+
+// Converts a frequency in MHz, 0 stays 0.
+func megaHertz(mhz float64) (physic.Frequency, error) {
+	if mhz < 0 || mhz > 10000 {
+		return 0, fmt.Errorf("Frequency %gMHz is out of range", mhz)
+	}
+	return physic.Frequency(math.Round(mhz*1e6)) * physic.Hertz, nil
+}
+
+// Some receivers need a wake-up preamble before the first frame. The preamble
+// consists of the given number of pulses, each one pulse length high followed
+// by one pulse length low. Pair and StartContinuous send it before every frame.
+// The default is 0, i.e., no preamble. SetProtocol resets it to the default.
+func (s *RCSwitch) SetPreamble(pulses int) error {
+	if pulses < 0 {
+		return errors.New("Preamble pulses must not be negative")
+	}
+	s.Lock()
+	s.protocol.preamble = pulses
+	s.Unlock()
+	return nil
+}
+
+// Some receivers need several sync bits to detect the end of a frame.
+// The default is 1. SetProtocol resets it to the default.
+func (s *RCSwitch) SetSyncRepeat(n int) error {
+	if n <= 0 {
+		return errors.New("Sync repeat has to be a positive number")
+	}
+	s.Lock()
+	s.protocol.syncRepeat = n
+	s.Unlock()
+	return nil
+}
+
+// Send numeric codes (e.g., decimal lines of a CodeWriter) least significant
+// bit first. Some HT6P20B style devices and sensors expect this. Tri-state code
+// words are always sent as they are written. The default is most significant
+// bit first. SetProtocol resets it to the default.
+func (s *RCSwitch) SetLSBFirst(lsbFirst bool) {
+	s.Lock()
+	s.protocol.lsbFirst = lsbFirst
+	s.Unlock()
+}
+
+// Restrict the code words this RCSwitch object may transmit.
+// Code words are tri-state strings (e.g., "0FFF0FFFFF0F") as sent by SwitchOn
+// and SwitchOff, or binary strings of any length (e.g., "000000000001010100010001")
+// as sent by Send, SwitchOnKaku, or encoders. A code word of only 0 and 1 is
+// both, it matches either way it is read.
+// If allow is not empty, only code words in allow are sent.
+// Code words in deny are never sent, even if they are also in allow (e.g., the
+// neighbor's garage door opener). Every transmission path checks the filter and
+// returns ErrCodeRejected for rejected code words. Raw pulse trains (SendRaw,
+// Replay) are rejected while a filter is set.
+// Calling SetCodeFilter(nil, nil) removes all restrictions, which is the default.
+func (s *RCSwitch) SetCodeFilter(allow, deny []string) error {
+	allowed, err := codeSet(allow)
+	if err != nil {
+		return err
+	}
+	denied, err := codeSet(deny)
+	if err != nil {
+		return err
+	}
+	s.Lock()
+	s.allowed, s.denied = allowed, denied
+	s.Unlock()
+	return nil
+}
+
+func codeSet(codes []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(codes))
+	for _, c := range codes {
+		if err := validTriState(c); err != nil {
+			return nil, err
+		}
+		set[triStateToBinary(c)] = true
+		if strings.Trim(c, "01") == "" {
+			set[c] = true
+		}
+	}
+	return set, nil
+}
+
 // Turn on a switch.
 // Group and device have to be set.
 // Family is only used for Type C. In the most common case family is unused and should be set to "".
 // Type A (most common): family: "", group: binary string (e.g. "11011"), device: binary string (e.g, "10000").
 // Type B: family: "", group: string 1-4 (e.g. "1"), device: string 1-4 (e.g, "2").
-// Type C: family: string a-f (e.g. "b"), group: string 1-4 (e.g. "1"), device: string 1-4 (e.g, "2").
-// Type D: family: "", group: string a-d (e.g. "a"), device: string 1-3 (e.g, "2").
-func (s *RCSwitch) SwitchOn(family, group, device string) error {
+// Type C: family: string a-p (e.g. "b"), group: string 1-4 (e.g. "1"), device: string 1-4 (e.g, "2").
+// Type D (REV Telecontrol): family: "", group: string a-d (e.g. "a"), device: string 1-3 (e.g, "2").
+// If the same switch is switched on concurrently by multiple callers, the
+// calls waiting for their transmission are coalesced into a single one.
+// Options override settings of the RCSwitch object for this call only, e.g.,
+// SwitchOn("", "11011", "10000", WithProtocol(2), WithRepeat(15)).
+func (s *RCSwitch) SwitchOn(family, group, device string, opts ...Option) error {
+	return s.SwitchOnCtx(context.Background(), family, group, device, opts...)
+}
+
+// Turn on a switch. Format is the same as for SwitchOn.
+func (s *RCSwitch) SwitchOff(family, group, device string, opts ...Option) error {
+	return s.SwitchOffCtx(context.Background(), family, group, device, opts...)
+}
+
+// Like SwitchOn, but the transmission is aborted between two waveforms when
+// ctx is done, leaving the pin low, and ctx.Err() is returned. Waiting for
+// other transmissions of the RCSwitch object to finish can not be aborted.
+// An aborted transmission does not change the tracked state.
+func (s *RCSwitch) SwitchOnCtx(ctx context.Context, family, group, device string, opts ...Option) error {
+	return s.switchCoalesced(ctx, newCommand(family, group, device, true, opts))
+}
+
+// Like SwitchOff, but can be aborted like SwitchOnCtx.
+func (s *RCSwitch) SwitchOffCtx(ctx context.Context, family, group, device string, opts ...Option) error {
+	return s.switchCoalesced(ctx, newCommand(family, group, device, false, opts))
+}
+
+// Switch a switch to the opposite of its tracked state (see IsOn) and return
+// the tracked state afterwards, which is unchanged if sending failed. Format
+// is the same as for SwitchOn. Reading and switching the state is atomic, so
+// concurrent toggles do not get lost. Toggles are not coalesced. State
+// tracking has to be enabled, see SetStateTracking.
+func (s *RCSwitch) Toggle(family, group, device string, opts ...Option) (bool, error) {
+	return s.ToggleCtx(context.Background(), family, group, device, opts...)
+}
+
+// Like Toggle, but can be aborted like SwitchOnCtx.
+func (s *RCSwitch) ToggleCtx(ctx context.Context, family, group, device string, opts ...Option) (bool, error) {
 	s.Lock()
 	defer s.Unlock()
-	code, err := getCodeWord(family, group, device, true)
+	if s.stateless {
+		return false, errors.New("Toggle needs state tracking, see SetStateTracking")
+	}
+	err := s.switchTo(ctx, newCommand(family, group, device, !s.isOn[group+device], opts))
+	return s.isOn[group+device], err
+}
+
+// Send a command and track its state. Has to be called with s locked.
+func (s *RCSwitch) switchTo(ctx context.Context, cmd Command) error {
+	code, err := s.codeWord(cmd.Family, cmd.Group, cmd.Device, cmd.On)
 	if err != nil {
 		return err
 	}
-	s.sendTriState(code)
-	// changing the codeword type between different calls to On/Off does not make sense, so group+device is unique
-	s.isOn[group+device] = true
+	prot, protNr, nrRepeat, err := s.commandProtocol(cmd)
+	if err != nil {
+		return err
+	}
+	err = s.sendRepeat(ctx, triStateToBinary(code), prot, nrRepeat)
+	s.metrics.observeCommand(cmd, err)
+	s.record(cmd, code, protNr, err)
+	if err != nil {
+		return err
+	}
+	s.cancelAutoOff(Switch{Family: cmd.Family, Group: cmd.Group, Device: cmd.Device})
+	s.setState(cmd.Group, cmd.Device, cmd.On)
+	s.setCommand(cmd)
 	return nil
 }
 
-// Turn on a switch. Format is the same as for SwitchOn.
-func (s *RCSwitch) SwitchOff(family, group, device string) error {
+// Returns the protocol, its number, and the number of frames to send cmd with,
+// taking the options of cmd into account. Has to be called with s locked.
+func (s *RCSwitch) commandProtocol(cmd Command) (protocol, int, int, error) {
+	prot, protNr := s.protocol, s.protocolNr
+	if cmd.Protocol != 0 {
+		if cmd.Protocol < 0 || cmd.Protocol > len(protocols) {
+			return protocol{}, 0, 0, fmt.Errorf("Protocol %d is not supported, supported are 1 to %d", cmd.Protocol, len(protocols))
+		}
+		prot, protNr = protocols[cmd.Protocol-1], cmd.Protocol
+	}
+	if cmd.Repeat < 0 {
+		return protocol{}, 0, 0, errors.New("Repeat must not be negative")
+	}
+	nrRepeat := s.repeat(prot)
+	if cmd.Repeat > 0 {
+		nrRepeat = cmd.Repeat
+	}
+	f, err := megaHertz(cmd.Frequency)
+	if err != nil {
+		return protocol{}, 0, 0, err
+	}
+	prot.frequency = f
+	return prot, protNr, nrRepeat, nil
+}
+
+// Progress callback used by Pair. It is called after every transmitted frame
+// with the number of frames sent so far and the time elapsed since pairing started.
+type PairProgress func(frames int, elapsed time.Duration)
+
+// Pair a self-learning socket.
+// Self-learning sockets have a learning mode (usually entered by plugging them
+// in or pressing their button) in which they store the first code they receive.
+// Pair sends the "on" code of the given switch continuously for duration d or
+// until ctx is done, whichever comes first. Format is the same as for SwitchOn.
+// If progress is not nil, it is called after every frame. It is called with the
+// RCSwitch object locked, so it must not call methods of the RCSwitch object.
+// If ctx is done before d has passed, ctx.Err() is returned. The state of the
+// switch is only set if pairing went on for d.
+func (s *RCSwitch) Pair(ctx context.Context, family, group, device string, d time.Duration, progress PairProgress) error {
+	if d <= 0 {
+		return errors.New("Pairing duration has to be positive")
+	}
 	s.Lock()
 	defer s.Unlock()
-	code, err := getCodeWord(family, group, device, false)
+	code, err := s.codeWord(family, group, device, true)
 	if err != nil {
 		return err
 	}
-	s.sendTriState(code)
-	s.isOn[group+device] = false
+
+	binary := triStateToBinary(code)
+	if err := s.checkSend(binary); err != nil {
+		return err
+	}
+
+	ws := s.waveform(binary, s.protocol)
+	if err := s.transmitContinuously(ctx, ws, s.protocol, d, progress); err != nil {
+		return err
+	}
+	s.setState(group, device, true)
 	return nil
 }
 
+// Start sending the code of a switch continuously, like holding down a button
+// of a remote. Some dimmers and blind motors interpret how long a button is held.
+// Format is the same as for SwitchOn/SwitchOff, on selects which of the two codes is sent.
+// Sending goes on in the background until the returned stop function is called.
+// Stop aborts the current frame, leaving the pin low. Calling it more than once is fine.
+// Stop returns nil, or the error that ended sending early, e.g., a failed
+// transmission or ErrClosed. The state of the switch is set once the first
+// frame was sent.
+// All other methods of the RCSwitch object block until stop is called.
+func (s *RCSwitch) StartContinuous(family, group, device string, on bool) (stop func() error, err error) {
+	s.Lock()
+	code, err := s.codeWord(family, group, device, on)
+	if err != nil {
+		s.Unlock()
+		return nil, err
+	}
+	binary := triStateToBinary(code)
+	if err := s.checkSend(binary); err != nil {
+		s.Unlock()
+		return nil, err
+	}
+	ws := s.waveform(binary, s.protocol)
+	started := func(frames int, elapsed time.Duration) {
+		if frames == 1 {
+			s.setState(group, device, on)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	var sendErr error
+	go func() {
+		defer close(done)
+		defer s.Unlock() // locked above, ownership is handed over to this goroutine
+		sendErr = s.transmitContinuously(ctx, ws, s.protocol, 0, started)
+	}()
+
+	return func() error {
+		cancel()
+		<-done
+		if sendErr == context.Canceled {
+			return nil // stopped by stop
+		}
+		return sendErr
+	}, nil
+}
+
+// Send single frames of ws until ctx is done, s gets closed, or d has passed.
+// A d of 0 means no time limit. Has to be called with s locked.
+func (s *RCSwitch) transmitContinuously(ctx context.Context, ws []waveform, prot protocol, d time.Duration, progress PairProgress) error {
+	start := time.Now()
+	for frames := 1; ; frames++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if s.isClosed() {
+			return ErrClosed
+		}
+		if err := s.transmit(ctx, ws, prot, 1); err != nil {
+			return err
+		}
+		elapsed := time.Since(start)
+		if progress != nil {
+			progress(frames, elapsed)
+		}
+		if d > 0 && elapsed >= d {
+			return nil
+		}
+	}
+}
+
+// Enable or disable tracking of the switch states (see IsOn), it is enabled by default.
+// If the states are managed elsewhere, e.g., in a relay forwarding codes for
+// many switches, disabling tracking avoids a growing map of states.
+// Disabling forgets all tracked states, IsOn then always returns false.
+func (s *RCSwitch) SetStateTracking(enabled bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.stateless = !enabled
+	if s.stateless {
+		s.isOn = make(map[string]bool)
+		s.dimLevel = nil
+		s.commands = nil
+	}
+}
+
+// Track the state of a switch. Has to be called with s locked.
+func (s *RCSwitch) setState(group, device string, on bool) {
+	for _, f := range s.stateChange {
+		f(group, device, on)
+	}
+	if s.stateless {
+		return
+	}
+	// changing the codeword type between different calls to On/Off does not make sense, so group+device is unique
+	s.isOn[group+device] = on
+}
+
 // Returns true if the switch is "on".
 // This is just a state kept within the RCSwitch object. It does not reflect
 // the physical state. For example if the switch was manually turned on, it is
@@ -137,35 +588,386 @@ func (s *RCSwitch) IsOn(group, device string) bool {
 	return s.isOn[group+device]
 }
 
-func (s *RCSwitch) sendTriState(tristate string) {
-	s.send(triStateToBinary(tristate))
+// Register f to be called whenever a switch was switched successfully, e.g.,
+// by SwitchOn, SwitchOff, queued commands, Pair, or StartContinuous, even if
+// the state did not change and with state tracking disabled. f is not called
+// for failed or aborted transmissions. f is called with the RCSwitch object
+// locked, so it must not call methods of the RCSwitch object and should return
+// quickly.
+func (s *RCSwitch) OnStateChange(f func(group, device string, on bool)) {
+	s.Lock()
+	s.stateChange = append(s.stateChange, f)
+	s.Unlock()
+}
+
+// Send a code given as number, e.g., a 24-bit code of an EV1527 style remote
+// as printed by a sniffer. This is the same as the upstream send(5393, 24).
+// The code is sent most significant bit first, unless SetLSBFirst was set.
+// Codes of up to 64 bits are supported, e.g., 36-bit codes of garage doors.
+// The state of switches is not tracked for codes sent this way.
+func (s *RCSwitch) Send(code uint64, bitLength int) error {
+	return s.SendCtx(context.Background(), code, bitLength)
+}
+
+// Like Send, but can be aborted like SwitchOnCtx.
+func (s *RCSwitch) SendCtx(ctx context.Context, code uint64, bitLength int) error {
+	s.Lock()
+	defer s.Unlock()
+	binary, err := decimalToBinary(code, bitLength, s.protocol.lsbFirst)
+	if err != nil {
+		return err
+	}
+	return s.send(ctx, binary)
+}
+
+// Send a tri-state code word, e.g., "0FFF0FFFFF0F" as printed by sniffers.
+// The code may only consist of 0, 1, and F. It is sent with the current
+// protocol, the state of switches is not tracked for codes sent this way.
+func (s *RCSwitch) SendTriState(code string) error {
+	return s.SendTriStateCtx(context.Background(), code)
+}
+
+// Like SendTriState, but can be aborted like SwitchOnCtx.
+func (s *RCSwitch) SendTriStateCtx(ctx context.Context, code string) error {
+	if err := validTriState(code); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	return s.sendTriState(ctx, code)
+}
+
+// Send a binary code word of arbitrary length, e.g., "000101010001010101010100"
+// as printed by sniffers. It is sent with the current protocol, the state of
+// switches is not tracked for codes sent this way.
+func (s *RCSwitch) SendBinary(code string) error {
+	return s.SendBinaryCtx(context.Background(), code)
+}
+
+// Like SendBinary, but can be aborted like SwitchOnCtx.
+func (s *RCSwitch) SendBinaryCtx(ctx context.Context, code string) error {
+	if code == "" || strings.Trim(code, "01") != "" {
+		return fmt.Errorf("Code word %q has to be a non-empty binary string of 0 and 1", code)
+	}
+	s.Lock()
+	defer s.Unlock()
+	return s.send(ctx, code)
+}
+
+// Send a raw pulse train, e.g., captured from a remote: alternating high and
+// low durations, starting with high. The pin is low after the last pulse.
+// Durations have a resolution of one microsecond. The pulse train is sent
+// once, the protocol settings do not apply. A raw pulse train can not be
+// checked against the code filter, so it is rejected with ErrCodeRejected
+// while a filter is set, see SetCodeFilter.
+func (s *RCSwitch) SendRaw(pulses []time.Duration) error {
+	return s.SendRawCtx(context.Background(), pulses)
+}
+
+// Like SendRaw, but can be aborted like SwitchOnCtx.
+func (s *RCSwitch) SendRawCtx(ctx context.Context, pulses []time.Duration) error {
+	if len(pulses) == 0 {
+		return errors.New("Pulse train is empty")
+	}
+	ws := make([]waveform, 0, (len(pulses)+1)/2)
+	for i, d := range pulses {
+		if d < time.Microsecond {
+			return fmt.Errorf("Pulse %d is shorter than 1µs", i)
+		}
+		us := int(d / time.Microsecond)
+		if i%2 == 0 {
+			ws = append(ws, waveform{high: us})
+		} else {
+			ws[len(ws)-1].low = us
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if err := s.checkRaw(); err != nil {
+		return err
+	}
+	return s.transmit(ctx, ws, rawProtocol, 1)
+}
+
+// Close the RCSwitch object.
+// Close stops accepting new commands, they fail with ErrClosed from now on.
+// Only the commands in the transmit queue (see EnqueueOn) are still sent. A transmission
+// that is in flight is finished (a running Pair is stopped after the current
+// frame), then the pin is driven low so the transmitter is not left keyed up,
+// and the lock file (see SetLockFile) and the history file (see SetHistoryFile)
+// are closed. Pending "off" commands of SwitchOnFor are dropped, but kept in
+// the auto-off file (see SetAutoOffFile), Schedule, Refresh, and Mirror return. If ctx is done before that, Close returns
+// ctx.Err(). The object is closed nevertheless, queued commands that were not
+// sent yet fail, and the pin is driven low as soon as the in-flight
+// transmission is done. Calling Close again only waits for the pin.
+func (s *RCSwitch) Close(ctx context.Context) error {
+	if atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		close(s.done)
+	}
+	s.closeQueue(ctx) // on error ctx is done, which is handled below
+	atomic.StoreInt32(&s.closed, 2)
+
+	done := make(chan error, 1)
+	go func() {
+		s.Lock()
+		defer s.Unlock()
+		var err error
+		if t, ok := s.tx.(*gpioTransmitter); ok && !s.dryRun {
+			err = t.pin.Out(t.idle())
+		} else if c, ok := s.tx.(io.Closer); ok {
+			err = c.Close()
+		}
+		if s.lockFile != nil {
+			if cerr := s.lockFile.f.Close(); err == nil {
+				err = cerr
+			}
+			s.lockFile = nil
+		}
+		if s.history.file != nil {
+			if cerr := s.history.file.Close(); err == nil {
+				err = cerr
+			}
+			s.history.file = nil
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Whether commands fail with ErrClosed. While Close drains the transmit queue,
+// only the queue worker may send. Has to be called with s locked.
+func (s *RCSwitch) isClosed() bool {
+	switch atomic.LoadInt32(&s.closed) {
+	case 0:
+		return false
+	case 1:
+		return !s.draining
+	}
+	return true
+}
+
+func (s *RCSwitch) sendTriState(ctx context.Context, tristate string) error {
+	return s.send(ctx, triStateToBinary(tristate))
 }
 
-func (s *RCSwitch) send(binary string) {
-	ws := binaryToWaveForm(binary, s.protocol)
-	transmit(&ws, s.protocol, s.nrRepeat, s.pin)
+func (s *RCSwitch) send(ctx context.Context, binary string) error {
+	return s.sendProtocol(ctx, binary, s.protocol)
+}
+
+func (s *RCSwitch) sendProtocol(ctx context.Context, binary string, prot protocol) error {
+	return s.sendRepeat(ctx, binary, prot, s.repeat(prot))
+}
+
+func (s *RCSwitch) sendRepeat(ctx context.Context, binary string, prot protocol, nrRepeat int) error {
+	if err := s.checkSend(binary); err != nil {
+		return err
+	}
+	ws := s.waveform(binary, prot)
+	return s.transmitVerified(ctx, binary, ws, prot, nrRepeat)
+}
+
+// Returns the number of frames per transmission for prot.
+func (s *RCSwitch) repeat(prot protocol) int {
+	if s.nrRepeat > 0 {
+		return s.nrRepeat
+	}
+	return prot.repeat
+}
+
+// Transmit on the pin and keep track of the pin health and the duty cycle.
+// Has to be called with s locked.
+func (s *RCSwitch) transmit(ctx context.Context, ws []waveform, prot protocol, nrRepeat int) error {
+	tr := newTransmission(ws, prot, nrRepeat)
+	tr.Gap = s.repeatGap
+	return s.transmitAll(ctx, []Transmission{tr}, nil)
+}
+
+// Like transmit for transmissions sent one after the other, separated by the
+// repeat gap. Together they count as a single transmission for the duty
+// cycle, the lock file, the report, and the metrics. If sent is not nil, it is
+// called with the index of every transmission sent successfully.
+// Has to be called with s locked.
+func (s *RCSwitch) transmitAll(ctx context.Context, trs []Transmission, sent func(i int)) error {
+	expected := time.Duration(len(trs)-1) * s.repeatGap
+	for _, tr := range trs {
+		expected += tr.duration()
+	}
+	if err := s.duty.wait(ctx, expected); err != nil {
+		if err == ErrDutyCycle {
+			s.metrics.observe(SendRejected, 0)
+		}
+		return err
+	}
+
+	start := time.Now()
+	r := TransmissionReport{Expected: expected, DryRun: s.dryRun}
+	sendAll := func() error {
+		for i, tr := range trs {
+			if i > 0 && s.repeatGap > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(s.repeatGap):
+				}
+			}
+			if tr.Frequency == 0 {
+				tr.Frequency = s.frequency
+			}
+			// the report warns about the shortest pulses
+			if r.PulseLength == 0 || tr.PulseLength < r.PulseLength {
+				r.PulseLength = tr.PulseLength
+			}
+			var err error
+			if s.dryRun {
+				err = simulate(ctx, tr)
+			} else {
+				err = s.tx.Transmit(ctx, tr)
+			}
+			if t, ok := s.tx.(*gpioTransmitter); ok && !s.dryRun {
+				r.Frames += t.frames
+				if t.maxOvershoot > r.MaxOvershoot {
+					r.MaxOvershoot = t.maxOvershoot
+				}
+			} else if err == nil {
+				r.Frames += tr.Repeat
+			}
+			if err != nil {
+				return err
+			}
+			if sent != nil {
+				sent(i)
+			}
+		}
+		return nil
+	}
+	var err error
+	if s.dryRun {
+		err = sendAll()
+	} else {
+		err = s.lockFile.do(sendAll)
+	}
+	r.Duration, r.Err = time.Since(start), err
+	s.duty.record(r.Duration)
+	s.report(r)
+	switch {
+	case err == nil:
+		s.health.record(err)
+		s.metrics.observe(SendOK, r.Duration)
+	case err == ctx.Err(): // aborted, not a failure of the pin
+		s.metrics.observe(SendAborted, r.Duration)
+	default:
+		s.health.record(err)
+		s.metrics.observe(SendFailed, r.Duration)
+	}
+	return err
+}
+
+func (s *RCSwitch) checkSend(binary string) error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if s.denied[binary] || (len(s.allowed) > 0 && !s.allowed[binary]) {
+		s.metrics.observe(SendRejected, 0)
+		return ErrCodeRejected
+	}
+	return nil
+}
+
+// Like checkSend for transmissions without a code word, which are rejected
+// while a code filter is set.
+func (s *RCSwitch) checkRaw() error {
+	if s.isClosed() {
+		return ErrClosed
+	}
+	if len(s.allowed) > 0 || len(s.denied) > 0 {
+		s.metrics.observe(SendRejected, 0)
+		return ErrCodeRejected
+	}
+	return nil
 }
 
 // The C++ implementation was called for every single waveform.
 // Handing over the whole slice without calling the function multiple times
 // (250 times is not uncommon with the default repeat factor) makes timing more
 // reliable. This was an issue on my old, first gen raspi.
-func transmit(ws *[]waveform, prot protocol, nrRepeat int, pin gpio.PinIO) {
-	d := prot.pulseLen * time.Microsecond
+func (t *gpioTransmitter) Transmit(ctx context.Context, tr Transmission) error {
+	d := tr.PulseLength
+	pin := t.pin
 
 	f, s := gpio.High, gpio.Low
-	if prot.inverted {
+	if tr.Inverted != t.activeLow {
 		f, s = s, f
 	}
 
-	for i := 0; i < nrRepeat; i++ {
-		for _, w := range *ws {
-			pin.Out(f)
-			time.Sleep(time.Duration(w.high) * d)
-			pin.Out(s)
-			time.Sleep(time.Duration(w.low) * d)
+	t.frames, t.maxOvershoot = 0, 0
+	overshoot := func(o time.Duration) {
+		if o > t.maxOvershoot {
+			t.maxOvershoot = o
+		}
+	}
+	sleep := func(d time.Duration) {
+		start := time.Now()
+		time.Sleep(d)
+		overshoot(time.Since(start) - d)
+	}
+	if t.mode == Precise {
+		// Deadlines are absolute, so errors do not add up over the transmission.
+		next := time.Now()
+		sleep = func(d time.Duration) {
+			next = next.Add(d)
+			WaitUntil(next)
+			overshoot(time.Since(next))
+		}
+	}
+
+	out := func(w Waveform) error {
+		select {
+		case <-ctx.Done():
+			pin.Out(t.idle())
+			return ctx.Err()
+		default:
+		}
+		if err := pin.Out(f); err != nil {
+			return err
+		}
+		sleep(time.Duration(w.High) * d)
+		if err := pin.Out(s); err != nil {
+			return err
+		}
+		sleep(time.Duration(w.Low) * d)
+		return nil
+	}
+
+	for i := 0; i < tr.Preamble; i++ {
+		if err := out(Waveform{1, 1}); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i < tr.Repeat; i++ {
+		if i > 0 && tr.Gap > 0 {
+			// Inverted frames end with the carrier on, the gap is silence.
+			if err := pin.Out(t.idle()); err != nil {
+				return err
+			}
+			sleep(tr.Gap)
 		}
+		for _, w := range tr.Frame {
+			if err := out(w); err != nil {
+				return err
+			}
+		}
+		t.frames++
 	}
+	// Inverted protocols end with the carrier on, like upstream switch it off.
+	return pin.Out(t.idle())
 }
 
 func getCodeWord(family, group, device string, status bool) (string, error) {
@@ -184,7 +986,7 @@ func getCodeWord(family, group, device string, status bool) (string, error) {
 			return "", errors.New("Protocols B/D have a device string that can be converted to an integer")
 		}
 		g, err := strconv.Atoi(group)
-		if err != nil { // Type B
+		if err == nil { // Type B
 			return getCodeWordB(g, d, status)
 		} else { // Type D
 			return getCodeWordD(group, d, status)
@@ -202,69 +1004,69 @@ func getCodeWordA(group, device string, status bool) (string, error) {
 		return "", errors.New("Device has to have a length of 5 encoded as binary (e.g., 10000)")
 	}
 
-	var codeword string
+	var codeword strings.Builder
 
 	for _, b := range group + device {
 		if b == '0' {
-			codeword += "F"
+			codeword.WriteString("F")
 		} else {
-			codeword += "0"
+			codeword.WriteString("0")
 		}
 	}
 
 	if status {
-		codeword += "0F"
+		codeword.WriteString("0F")
 	} else {
-		codeword += "F0"
+		codeword.WriteString("F0")
 	}
 
-	return codeword, nil
+	return codeword.String(), nil
 }
 
-// This is untested, if you can test it, please send a pull request removing this comment and add a test case.
 func getCodeWordB(group, device int, status bool) (string, error) {
 	if group < 1 || group > 4 || device < 1 || device > 4 {
 		return "", errors.New("Group and device have to be within the range of 1 to 4")
 	}
 
-	var codeword string
+	var codeword strings.Builder
 	for i := 1; i <= 4; i++ {
 		if group == i {
-			codeword += "0"
+			codeword.WriteString("0")
 		} else {
-			codeword += "F"
+			codeword.WriteString("F")
 		}
 	}
 
 	for i := 1; i <= 4; i++ {
 		if device == i {
-			codeword += "0"
+			codeword.WriteString("0")
 		} else {
-			codeword += "F"
+			codeword.WriteString("F")
 		}
 	}
 
-	codeword += "FFF"
+	codeword.WriteString("FFF")
 
 	if status {
-		codeword += "F"
+		codeword.WriteString("F")
 	} else {
-		codeword += "0"
+		codeword.WriteString("0")
 	}
 
-	return codeword, nil
+	return codeword.String(), nil
 }
 
-// This is untested, if you can test it, please send a pull request removing this comment and add a test case.
 func getCodeWordC(family, group, device string, status bool) (string, error) {
 	if len(family) != 1 {
 		return "", errors.New("Family has to be a single character")
 	}
 
-	f, err := strconv.ParseUint(family, 16, 8) // implicetly contains a..f check
-	if err != nil {
-		return "", err
+	// like upstream, a is 0 and p is 15
+	f := strings.ToLower(family)[0]
+	if f < 'a' || f > 'p' {
+		return "", errors.New("Family has to be in a-p or A-P")
 	}
+	f -= 'a'
 
 	g, err := strconv.Atoi(group)
 	if err != nil {
@@ -282,64 +1084,66 @@ func getCodeWordC(family, group, device string, status bool) (string, error) {
 		return "", errors.New("Device has to be between 1 and 4")
 	}
 
-	var codeword string
+	var codeword strings.Builder
 
 	for i := uint(0); i < 4; i++ {
 		if (f & 0x1) == 0x1 {
-			codeword += "F"
+			codeword.WriteString("F")
 		} else {
-			codeword += "0"
+			codeword.WriteString("0")
 		}
 		f >>= 1
 	}
 
 	conf := func(i int) string {
-		var s string
+		var s strings.Builder
 		iu := uint(i) - 1
 		if iu&0x1 == 1 {
-			s += "F"
+			s.WriteString("F")
 		} else {
-			s += "0"
+			s.WriteString("0")
 		}
-		if iu&0x2 == 1 {
-			s += "F"
+		if iu&0x2 != 0 {
+			s.WriteString("F")
 		} else {
-			s += "0"
+			s.WriteString("0")
 		}
-		return s
+		return s.String()
 	}
 
-	codeword += conf(d)
-	codeword += conf(g)
+	codeword.WriteString(conf(d))
+	codeword.WriteString(conf(g))
 
 	// status
-	codeword += "0FF"
+	codeword.WriteString("0FF")
 	if status {
-		codeword += "F"
+		codeword.WriteString("F")
 	} else {
-		codeword += "0"
+		codeword.WriteString("0")
 	}
 
-	return codeword, nil
+	return codeword.String(), nil
 }
 
-// This is untested, if you can test it, please send a pull request removing this comment and add a test case.
+// Type D is the encoding of REV Telecontrol sockets, following upstream:
+// 4 tri-state bits group (a = 1FFF, ..., d = FFF1), 3 bits device (1 = 1FF,
+// 2 = F1F, 3 = FF1), 3 unused bits (000), and 2 bits status (on = 10, off = 01).
 func getCodeWordD(group string, device int, status bool) (string, error) {
 	if len(group) != 1 {
 		return "", errors.New("Group has to be a single character")
 	}
 
-	var codeword string
+	var codeword strings.Builder
 
 	switch strings.ToLower(group) {
 	case "a":
-		codeword += "1FFF"
+		codeword.WriteString("1FFF")
 	case "b":
-		codeword += "F1FF"
+		codeword.WriteString("F1FF")
 	case "c":
-		codeword += "FF1F"
+		codeword.WriteString("FF1F")
 	case "d":
-		codeword += "FFF1"
+		codeword.WriteString("FFF1")
 	default:
 		return "", errors.New("Group has to be in a-d or A-D")
 	}
@@ -347,41 +1151,66 @@ func getCodeWordD(group string, device int, status bool) (string, error) {
 	//TODO(rck): this matches the implementation, but the upstream description is different, bug got reported upstream
 	switch device {
 	case 1:
-		codeword += "1FF"
+		codeword.WriteString("1FF")
 	case 2:
-		codeword += "F1F"
+		codeword.WriteString("F1F")
 	case 3:
-		codeword += "FF1"
+		codeword.WriteString("FF1")
 	default:
 		return "", errors.New("Group has to be in the range of 1..3")
 	}
 
 	// unused
-	codeword += "000"
+	codeword.WriteString("000")
 
 	// status
 	if status {
-		codeword += "10"
+		codeword.WriteString("10")
 	} else {
-		codeword += "01"
+		codeword.WriteString("01")
 	}
 
-	return codeword, nil
+	return codeword.String(), nil
 }
 
 func triStateToBinary(tristate string) string {
-	var binary string
+	var binary strings.Builder
+	binary.Grow(2 * len(tristate))
 	for _, c := range tristate {
 		switch c {
 		case '0':
-			binary += "00"
+			binary.WriteString("00")
 		case '1':
-			binary += "11"
+			binary.WriteString("11")
 		case 'F':
-			binary += "01"
+			binary.WriteString("01")
 		}
 	}
-	return binary
+	return binary.String()
+}
+
+// Maximum number of frames kept by waveform, e.g., on and off of 64 switches.
+const waveformCacheSize = 128
+
+type waveformKey struct {
+	binary string
+	prot   protocol
+}
+
+// Returns the frame of binary like binaryToWaveForm. Frames are cached, so
+// switching the same switches over and over does not allocate new ones. The
+// returned slice must not be modified. Has to be called with s locked.
+func (s *RCSwitch) waveform(binary string, prot protocol) []waveform {
+	key := waveformKey{binary: binary, prot: prot}
+	if ws, ok := s.waveforms[key]; ok {
+		return ws
+	}
+	if s.waveforms == nil || len(s.waveforms) >= waveformCacheSize {
+		s.waveforms = make(map[waveformKey][]waveform)
+	}
+	ws := binaryToWaveForm(binary, prot)
+	s.waveforms[key] = ws
+	return ws
 }
 
 func binaryToWaveForm(binary string, prot protocol) []waveform {
@@ -394,5 +1223,8 @@ func binaryToWaveForm(binary string, prot protocol) []waveform {
 		}
 	}
 	ws = append(ws, prot.syncBit)
+	for i := 1; i < prot.syncRepeat; i++ {
+		ws = append(ws, prot.syncBit)
+	}
 	return ws
 }
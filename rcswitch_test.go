@@ -0,0 +1,295 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestGetCodeWord(t *testing.T) {
+	tests := []struct {
+		family, group, device string
+		on                    bool
+		want                  string // empty if an error is expected
+	}{
+		// Type A, DIP switches
+		{"", "11001", "01000", true, "00FF0F0FFF0F"},
+		{"", "11001", "01000", false, "00FF0F0FFFF0"},
+		{"", "11011", "10000", true, "00F000FFFF0F"},
+		{"", "1101", "10000", true, ""},
+		// Type B, two rotary switches
+		{"", "1", "1", true, "0FFF0FFFFFFF"},
+		{"", "2", "3", true, "F0FFFF0FFFFF"},
+		{"", "4", "2", false, "FFF0F0FFFFF0"},
+		{"", "5", "1", true, ""},
+		{"", "1", "0", true, ""},
+		// Type C, Intertechno
+		{"a", "1", "1", true, "000000000FFF"},
+		{"b", "1", "2", true, "F000F0000FFF"},
+		{"c", "2", "3", true, "0F000FF00FFF"},
+		{"P", "4", "4", false, "FFFFFFFF0FF0"},
+		{"q", "1", "1", true, ""},
+		{"1", "1", "1", true, ""},
+		{"a", "5", "1", true, ""},
+		// Type D, REV
+		{"", "a", "1", true, "1FFF1FF00010"},
+		{"", "B", "2", true, "F1FFF1F00010"},
+		{"", "d", "3", false, "FFF1FF100001"},
+		{"", "e", "1", true, ""},
+		{"", "a", "4", true, ""},
+	}
+	for _, tt := range tests {
+		got, err := getCodeWord(tt.family, tt.group, tt.device, tt.on)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("getCodeWord(%q, %q, %q, %v) = %q, expected an error", tt.family, tt.group, tt.device, tt.on, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("getCodeWord(%q, %q, %q, %v) failed: %v", tt.family, tt.group, tt.device, tt.on, err)
+		case got != tt.want:
+			t.Errorf("getCodeWord(%q, %q, %q, %v) = %q, expected %q", tt.family, tt.group, tt.device, tt.on, got, tt.want)
+		}
+	}
+}
+
+func TestCodeFilter(t *testing.T) {
+	s := NewRCSwitch(nil)
+	s.SetTransmitter(nopTransmitter{})
+	// "0FFF0FFFFFFF" is group 1 device 1 of Type B on, 0x001511 is a 24 bit
+	// code, and "0101" is tri-state "00110011" as well as binary "0101"
+	if err := s.SetCodeFilter([]string{"0FFF0FFFFFFF", "000000000001010100010001", "0101"}, []string{"0FFF0FFFFFF0"}); err != nil {
+		t.Fatal(err)
+	}
+	sends := []struct {
+		name string
+		send func() error
+		ok   bool
+	}{
+		{"tri-state", func() error { return s.SwitchOn("", "1", "1") }, true},
+		{"denied tri-state", func() error { return s.SwitchOff("", "1", "1") }, false},
+		{"other tri-state", func() error { return s.SwitchOn("", "2", "1") }, false},
+		{"binary", func() error { return s.Send(0x001511, 24) }, true},
+		{"other binary", func() error { return s.Send(0x001511, 25) }, false},
+		{"binary of 0 and 1", func() error { return s.Send(0x5, 4) }, true},
+		{"tri-state of 0 and 1", func() error { return s.Send(0x33, 8) }, true},
+		{"raw", func() error { return s.SendRaw([]time.Duration{time.Millisecond}) }, false},
+	}
+	for _, tt := range sends {
+		err := tt.send()
+		if tt.ok && err != nil {
+			t.Errorf("%s: sending failed: %v", tt.name, err)
+		}
+		if !tt.ok && err != ErrCodeRejected {
+			t.Errorf("%s: sending returned %v, expected ErrCodeRejected", tt.name, err)
+		}
+	}
+
+	if err := s.SetCodeFilter([]string{"0F2"}, nil); err == nil {
+		t.Error("SetCodeFilter accepted an invalid code word")
+	}
+}
+
+func TestStartContinuous(t *testing.T) {
+	s := NewRCSwitch(nil)
+	sent := make(chan struct{}, 1)
+	s.SetTransmitter(signalTransmitter{sent: sent})
+	stop, err := s.StartContinuous("", "1", "1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+	if err := stop(); err != nil {
+		t.Errorf("Stop returned %v", err)
+	}
+	if !s.IsOn("1", "1") {
+		t.Error("Switch is off after sending continuously")
+	}
+
+	broken := errors.New("Transmitter broken")
+	sent = make(chan struct{}, 1)
+	s.SetTransmitter(signalTransmitter{sent: sent, err: broken})
+	stop, err = s.StartContinuous("", "1", "2", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+	if err := stop(); err != broken {
+		t.Errorf("Stop returned %v, expected %v", err, broken)
+	}
+	if s.IsOn("1", "2") {
+		t.Error("Switch is on although sending failed")
+	}
+}
+
+func TestPair(t *testing.T) {
+	s := NewRCSwitch(nil)
+	s.SetTransmitter(nopTransmitter{})
+	if err := s.Pair(context.Background(), "", "1", "1", time.Nanosecond, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !s.IsOn("1", "1") {
+		t.Error("Switch is off after pairing")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Pair(ctx, "", "1", "2", time.Second, nil); err != context.Canceled {
+		t.Errorf("Aborted pairing returned %v", err)
+	}
+	if s.IsOn("1", "2") {
+		t.Error("Switch is on although pairing was aborted")
+	}
+
+	broken := errors.New("Transmitter broken")
+	s.SetTransmitter(signalTransmitter{sent: make(chan struct{}, 1), err: broken})
+	if err := s.Pair(context.Background(), "", "1", "3", time.Second, nil); err != broken {
+		t.Errorf("Pairing returned %v, expected %v", err, broken)
+	}
+	if s.IsOn("1", "3") {
+		t.Error("Switch is on although pairing failed")
+	}
+}
+
+func TestOnStateChange(t *testing.T) {
+	s := NewRCSwitch(nil)
+	var changes []string
+	s.OnStateChange(func(group, device string, on bool) {
+		changes = append(changes, fmt.Sprintf("%s%s %v", group, device, on))
+	})
+
+	s.SetTransmitter(signalTransmitter{sent: make(chan struct{}, 1), err: errors.New("Transmitter broken")})
+	s.SwitchOn("", "1", "1")
+	s.Pair(context.Background(), "", "1", "2", time.Second, nil)
+	if stop, err := s.StartContinuous("", "1", "3", true); err == nil {
+		stop()
+	}
+	if len(changes) != 0 {
+		t.Errorf("Failed transmissions changed states: %v", changes)
+	}
+
+	s.SetTransmitter(nopTransmitter{})
+	if err := s.SwitchOn("", "1", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SwitchOff("", "1", "1"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"11 true", "11 false"}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("States changed %v, expected %v", changes, want)
+	}
+}
+
+// Transmitter that returns immediately, so benchmarks measure everything but the air time.
+type nopTransmitter struct{}
+
+func (nopTransmitter) Transmit(context.Context, Transmission) error { return nil }
+
+// Transmitter that signals every transmission on sent, if it has room, and
+// returns err.
+type signalTransmitter struct {
+	sent chan struct{}
+	err  error
+}
+
+func (tx signalTransmitter) Transmit(context.Context, Transmission) error {
+	select {
+	case tx.sent <- struct{}{}:
+	default:
+	}
+	return tx.err
+}
+
+func BenchmarkSwitchOn(b *testing.B) {
+	s := NewRCSwitch(nil)
+	s.SetTransmitter(nopTransmitter{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := s.SwitchOn("", "11011", "10000"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCodeWord(b *testing.B) {
+	s := NewRCSwitch(nil)
+	types := []struct{ name, family, group, device string }{
+		{"A", "", "11011", "10000"},
+		{"B", "", "2", "3"},
+		{"C", "b", "1", "2"},
+		{"D", "", "a", "2"},
+	}
+	for _, t := range types {
+		b.Run(t.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				code, err := s.codeWord(t.family, t.group, t.device, true)
+				if err != nil {
+					b.Fatal(err)
+				}
+				triStateToBinary(code)
+			}
+		})
+	}
+}
+
+func BenchmarkWaveform(b *testing.B) {
+	const binary = "000101010001010101010100"
+	s := NewRCSwitch(nil)
+	b.Run("Hit", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.waveform(binary, s.protocol)
+		}
+	})
+	b.Run("Miss", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.waveforms = nil
+			s.waveform(binary, s.protocol)
+		}
+	})
+}
+
+func TestFrequency(t *testing.T) {
+	s := NewRCSwitch(nil)
+	var got []Transmission
+	s.SetTransmitter(allTransmitter{&got})
+	r := NewDeviceRegistry()
+	if err := r.Register(Device{Name: "garage", Group: "1", Device: "2", Frequency: 315}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(Device{Name: "broken", Group: "1", Device: "3", Frequency: -1}); err == nil {
+		t.Error("Registered a negative frequency")
+	}
+	s.SetDeviceRegistry(r)
+
+	if err := s.SwitchOn("", "1", "1"); err != nil { // keeps the one of the transmitter
+		t.Fatal(err)
+	}
+	if err := s.SetFrequency(868.35); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SwitchOn("", "1", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.On("garage"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Toggle("", "1", "1", WithFrequency(433.92)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []physic.Frequency{0, 868350 * physic.KiloHertz, 315 * physic.MegaHertz, 433920 * physic.KiloHertz}
+	if len(got) != len(want) {
+		t.Fatalf("Sent %d transmissions, expected %d", len(got), len(want))
+	}
+	for i, tr := range got {
+		if tr.Frequency != want[i] {
+			t.Errorf("Transmission %d on %v, expected %v", i, tr.Frequency, want[i])
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Package rcswitchtest provides a fake pin to test code using rcswitch without
+// hardware. The pin records every level transition with a timestamp, the
+// recorded pulse train can be checked against the expected code word.
+//
+//	pin := rcswitchtest.NewPin("GPIO17")
+//	rc := rcswitch.NewRCSwitch(pin)
+//	rc.Send(5393, 24)
+//	err := pin.Expect("000000000001010100010001", rcswitch.Protocols()[0], 10)
+package rcswitchtest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rck/rcswitch"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/gpio/gpiotest"
+)
+
+// A Transition is a change of the level of a Pin.
+type Transition struct {
+	Level gpio.Level
+	Time  time.Time
+}
+
+// Pin is a fake gpio.PinIO recording level transitions.
+// Out calls that do not change the level are not recorded.
+type Pin struct {
+	gpiotest.Pin
+
+	// Maximum deviation of a recorded pulse from the expected one, as fraction
+	// of the pulse length. time.Sleep tends to overshoot, NewPin sets it to 0.5.
+	Tolerance float64
+
+	mu          sync.Mutex
+	transitions []Transition
+	now         func() time.Time // time.Now if nil, replaced by tests
+}
+
+// Create a Pin with the given name.
+func NewPin(name string) *Pin {
+	return &Pin{Pin: gpiotest.Pin{N: name}, Tolerance: 0.5}
+}
+
+// Out implements gpio.PinOut.
+func (p *Pin) Out(l gpio.Level) error {
+	now := time.Now()
+	if p.now != nil {
+		now = p.now()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := len(p.transitions); n == 0 || p.transitions[n-1].Level != l {
+		p.transitions = append(p.transitions, Transition{Level: l, Time: now})
+	}
+	return p.Pin.Out(l)
+}
+
+// Returns the recorded transitions, oldest first.
+func (p *Pin) Transitions() []Transition {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Transition(nil), p.transitions...)
+}
+
+// Forget the recorded transitions.
+func (p *Pin) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transitions = nil
+}
+
+// A Pulse is a period of constant level between two transitions.
+type Pulse struct {
+	Level    gpio.Level
+	Duration time.Duration
+}
+
+// Returns the recorded pulses. The level after the last transition has no
+// duration yet and is not part of the result.
+func (p *Pin) Pulses() []Pulse {
+	ts := p.Transitions()
+	var pulses []Pulse
+	for i := 1; i < len(ts); i++ {
+		pulses = append(pulses, Pulse{Level: ts[i-1].Level, Duration: ts[i].Time.Sub(ts[i-1].Time)})
+	}
+	return pulses
+}
+
+// Returns how often the frame of the binary code word (e.g., "0101") sent with
+// prot was recorded. Frames are counted if they follow each other without
+// other pulses in between. The last low period of the last frame can not be
+// measured, it is not checked.
+func (p *Pin) Frames(binary string, prot rcswitch.ProtocolInfo) (int, error) {
+	if binary == "" || strings.Trim(binary, "01") != "" {
+		return 0, fmt.Errorf("Code word %q is not a binary string", binary)
+	}
+	var frame []rcswitch.Waveform
+	for _, b := range binary {
+		if b == '1' {
+			frame = append(frame, prot.One)
+		} else {
+			frame = append(frame, prot.Zero)
+		}
+	}
+	for i := 0; i < prot.SyncRepeat || i == 0; i++ {
+		frame = append(frame, prot.Sync)
+	}
+
+	active := gpio.High
+	if prot.Inverted {
+		active = gpio.Low
+	}
+	tolerance := time.Duration(p.Tolerance * float64(prot.PulseLength))
+	pulses := p.Pulses()
+	match := func(i int, level gpio.Level, n int) bool {
+		d := pulses[i].Duration - time.Duration(n)*prot.PulseLength
+		if d < 0 {
+			d = -d
+		}
+		return pulses[i].Level == level && d <= tolerance
+	}
+
+	// Try to match a frame at pulse i, returns the index after the frame or -1.
+	matchFrame := func(i int) int {
+		for j, w := range frame {
+			if i >= len(pulses) || !match(i, active, w.High) {
+				return -1
+			}
+			i++
+			if i == len(pulses) { // end of the recording
+				if j == len(frame)-1 {
+					return i
+				}
+				return -1
+			}
+			if !match(i, !active, w.Low) {
+				return -1
+			}
+			i++
+		}
+		return i
+	}
+
+	best := 0
+	for start := range pulses {
+		n := 0
+		for i := start; i < len(pulses); n++ {
+			if i = matchFrame(i); i < 0 {
+				break
+			}
+		}
+		if n > best {
+			best = n
+		}
+	}
+	return best, nil
+}
+
+// Returns an error unless the frame of the binary code word was recorded
+// exactly repeat times in a row, see Frames.
+func (p *Pin) Expect(binary string, prot rcswitch.ProtocolInfo, repeat int) error {
+	n, err := p.Frames(binary, prot)
+	if err != nil {
+		return err
+	}
+	if n != repeat {
+		return fmt.Errorf("Code word %s was sent %d times in a row, expected %d", binary, n, repeat)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package rcswitchtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rck/rcswitch"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// Pin with a fake clock, play puts pulses on it without waiting.
+type fakePin struct {
+	*Pin
+	clock time.Time
+}
+
+func newFakePin() *fakePin {
+	f := &fakePin{Pin: NewPin("GPIO17"), clock: time.Unix(0, 0)}
+	f.now = func() time.Time { return f.clock }
+	return f
+}
+
+// Hold level for d.
+func (f *fakePin) hold(l gpio.Level, d time.Duration) {
+	f.Out(l)
+	f.clock = f.clock.Add(d)
+}
+
+// Send the frame of binary repeat times with prot, every pulse stretched by stretch.
+func (f *fakePin) play(binary string, prot rcswitch.ProtocolInfo, repeat int, stretch time.Duration) {
+	active, idle := gpio.High, gpio.Low
+	if prot.Inverted {
+		active, idle = idle, active
+	}
+	wave := func(w rcswitch.Waveform) {
+		f.hold(active, time.Duration(w.High)*prot.PulseLength+stretch)
+		f.hold(idle, time.Duration(w.Low)*prot.PulseLength+stretch)
+	}
+	for r := 0; r < repeat; r++ {
+		for _, b := range binary {
+			if b == '1' {
+				wave(prot.One)
+			} else {
+				wave(prot.Zero)
+			}
+		}
+		wave(prot.Sync)
+	}
+	f.Out(gpio.Low)
+}
+
+func TestTransitions(t *testing.T) {
+	f := newFakePin()
+	f.hold(gpio.High, time.Millisecond)
+	f.hold(gpio.High, time.Millisecond) // not a transition
+	f.hold(gpio.Low, 2*time.Millisecond)
+	f.Out(gpio.High)
+
+	ts := f.Transitions()
+	if len(ts) != 3 {
+		t.Fatalf("Recorded %d transitions, expected 3", len(ts))
+	}
+	want := []Pulse{{gpio.High, 2 * time.Millisecond}, {gpio.Low, 2 * time.Millisecond}}
+	pulses := f.Pulses()
+	if len(pulses) != len(want) {
+		t.Fatalf("Pulses() = %v, expected %v", pulses, want)
+	}
+	for i := range want {
+		if pulses[i] != want[i] {
+			t.Errorf("Pulses()[%d] = %v, expected %v", i, pulses[i], want[i])
+		}
+	}
+
+	f.Reset()
+	if ts := f.Transitions(); len(ts) != 0 {
+		t.Errorf("Recorded %d transitions after Reset, expected none", len(ts))
+	}
+}
+
+func TestFrames(t *testing.T) {
+	prot1 := rcswitch.Protocols()[0]
+	prot6 := rcswitch.Protocols()[5]
+	tests := []struct {
+		name    string
+		code    string // sent code word
+		prot    rcswitch.ProtocolInfo
+		repeat  int
+		stretch time.Duration
+		binary  string
+		flip    bool // send with the polarity swapped
+		want    int
+	}{
+		{"exact", "0101", prot1, 3, 0, "0101", false, 3},
+		{"single", "0101", prot1, 1, 0, "0101", false, 1},
+		{"inverted", "0011", prot6, 2, 0, "0011", false, 2},
+		{"within tolerance", "0101", prot1, 2, prot1.PulseLength * 4 / 10, "0101", false, 2},
+		{"beyond tolerance", "0101", prot1, 2, prot1.PulseLength * 6 / 10, "0101", false, 0},
+		{"other code", "0101", prot1, 2, 0, "0110", false, 0},
+		{"prefix", "010", prot1, 2, 0, "0101", false, 0},
+		{"wrong polarity", "0011", prot6, 2, 0, "0011", true, 0},
+	}
+	for _, tt := range tests {
+		f := newFakePin()
+		sent := tt.prot
+		sent.Inverted = sent.Inverted != tt.flip
+		f.play(tt.code, sent, tt.repeat, tt.stretch)
+		n, err := f.Frames(tt.binary, tt.prot)
+		if err != nil {
+			t.Errorf("%s: Frames failed: %v", tt.name, err)
+			continue
+		}
+		if n != tt.want {
+			t.Errorf("%s: Frames() = %d, expected %d", tt.name, n, tt.want)
+		}
+	}
+}
+
+func TestFramesAfterNoise(t *testing.T) {
+	prot := rcswitch.Protocols()[0]
+	f := newFakePin()
+	f.hold(gpio.High, 7*time.Millisecond)
+	f.hold(gpio.Low, 13*time.Millisecond)
+	f.play("1100", prot, 2, 0)
+	if err := f.Expect("1100", prot, 2); err != nil {
+		t.Error(err)
+	}
+	if err := f.Expect("1100", prot, 3); err == nil {
+		t.Error("Expect succeeds with the wrong repeat count")
+	}
+}
+
+func TestFramesInvalidCode(t *testing.T) {
+	f := newFakePin()
+	for _, binary := range []string{"", "01F0", "0120"} {
+		if _, err := f.Frames(binary, rcswitch.Protocols()[0]); err == nil {
+			t.Errorf("Frames(%q) does not fail", binary)
+		}
+	}
+}
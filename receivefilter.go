@@ -0,0 +1,83 @@
+package rcswitch
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReceiveFilter selects the codes delivered by Codes of a Receiver, see
+// SetReceiveFilter. Empty lists accept everything.
+type ReceiveFilter struct {
+	Protocols  []int // protocol numbers as used by SetProtocol
+	BitLengths []int
+	Codes      []uint64 // code values, regardless of bit length and protocol
+}
+
+func (f ReceiveFilter) accepts(c ReceivedCode) bool {
+	return containsInt(f.Protocols, c.Protocol) && containsInt(f.BitLengths, c.BitLength) && containsUint64(f.Codes, c.Value)
+}
+
+// Only deliver codes matching f on the channel returned by Codes, the zero
+// ReceiveFilter delivers all codes, which is the default.
+func (r *Receiver) SetReceiveFilter(f ReceiveFilter) error {
+	for _, p := range f.Protocols {
+		if p < 1 || p > len(protocols) {
+			return fmt.Errorf("Protocol %d of the filter is not supported, supported are 1 to %d", p, len(protocols))
+		}
+	}
+	r.filterMu.Lock()
+	defer r.filterMu.Unlock()
+	r.filter = f
+	return nil
+}
+
+// Remotes repeat their code as long as a button is pressed, and the Receiver
+// reports it every other repeat. With a positive window, a code identical to
+// the previous one (same value, bit length, and protocol) is only delivered
+// on Codes if it was not received within window, so a button press is
+// reported once. Every suppressed repeat restarts the window, a button held
+// down is reported once as well. 0 delivers every code, which is the default.
+// Codes filtered by SetReceiveFilter do not count.
+func (r *Receiver) SetDeduplication(window time.Duration) error {
+	if window < 0 {
+		return errors.New("Deduplication window must not be negative")
+	}
+	r.filterMu.Lock()
+	defer r.filterMu.Unlock()
+	r.dedupe = window
+	return nil
+}
+
+// Whether c passes filter and deduplication.
+func (r *Receiver) accept(c ReceivedCode) bool {
+	r.filterMu.Lock()
+	defer r.filterMu.Unlock()
+	if !r.filter.accepts(c) {
+		return false
+	}
+
+	now := time.Now()
+	repeat := r.dedupe > 0 && now.Sub(r.lastSeen) < r.dedupe &&
+		c.Value == r.last.Value && c.BitLength == r.last.BitLength && c.Protocol == r.last.Protocol
+	r.last, r.lastSeen = c, now
+	return !repeat
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return len(list) == 0
+}
+
+func containsUint64(list []uint64, v uint64) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return len(list) == 0
+}
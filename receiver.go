@@ -0,0 +1,292 @@
+package rcswitch
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// A long stretch without level change that might be the gap between two transmissions.
+const separationLimit = 4300 * time.Microsecond
+
+// Maximum number of level changes within one transmission, enough for 64 bits.
+const maxChanges = 2*64 + 3
+
+// Level changes shorter than this are glitches of noisy receivers, no protocol
+// uses pulses that short.
+const glitchLimit = 50 * time.Microsecond
+
+// How long the edge loop waits for an edge before checking whether it should stop.
+const edgeTimeout = 100 * time.Millisecond
+
+// ReceivedCode is a code decoded by a Receiver.
+type ReceivedCode struct {
+	Value       uint64
+	BitLength   int
+	Protocol    int // as used by SetProtocol
+	PulseLength time.Duration
+}
+
+// The Receiver object decodes codes received by a 433/315MHz receiver module
+// connected to a pin. This follows the upstream receive implementation: the
+// durations between level changes are recorded, and once the same transmission
+// was seen twice, it is decoded against all known protocols and the best
+// matching one is reported.
+type Receiver struct {
+	decoded uint64 // accessed atomically, first for 64 bit alignment on 32 bit platforms, see Decoded
+
+	pin   gpio.PinIO
+	codes chan ReceivedCode
+	done  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+
+	tolerance int32 // in percent, accessed atomically, see SetReceiveTolerance
+
+	listeners   map[chan ReceivedCode]bool // internal consumers, e.g., SetVerification
+	listenersMu sync.Mutex
+
+	filter   ReceiveFilter // see SetReceiveFilter
+	dedupe   time.Duration // see SetDeduplication
+	last     ReceivedCode  // last code that passed the filter
+	lastSeen time.Time
+	filterMu sync.Mutex
+
+	// Only accessed by the edge loop.
+	timings     [maxChanges]time.Duration
+	changeCount int
+	repeatCount int
+}
+
+// Create a Receiver object for the given pin and start receiving.
+// Decoded codes are delivered on the channel returned by Codes.
+func NewReceiver(pin gpio.PinIO) (*Receiver, error) {
+	if err := pin.In(gpio.Float, gpio.BothEdges); err != nil {
+		return nil, err
+	}
+
+	r := &Receiver{
+		pin:       pin,
+		codes:     make(chan ReceivedCode, 16),
+		done:      make(chan struct{}),
+		tolerance: 60,
+	}
+	r.wg.Add(1)
+	go r.loop()
+	return r, nil
+}
+
+// Returns the channel received codes are delivered on, see SetReceiveFilter
+// and SetDeduplication to reduce them. If codes are not read fast enough, new
+// ones are dropped.
+// The channel is closed by Close.
+func (r *Receiver) Codes() <-chan ReceivedCode {
+	return r.codes
+}
+
+// Stop receiving and close the channel returned by Codes.
+func (r *Receiver) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+		r.wg.Wait()
+		close(r.codes)
+	})
+	return r.pin.In(gpio.Float, gpio.NoEdge)
+}
+
+// Returns the number of codes decoded so far, including the ones dropped by
+// the receive filter, deduplication, or because Codes was not read.
+func (r *Receiver) Decoded() uint64 {
+	return atomic.LoadUint64(&r.decoded)
+}
+
+// Set how far recorded timings may deviate from the waveforms of a protocol,
+// in percent of the pulse length. The default is 60, like upstream. Widening
+// it helps with noisy receivers and remotes that are slightly off-spec, but
+// also decodes more noise.
+func (r *Receiver) SetReceiveTolerance(percent int) error {
+	if percent <= 0 || percent > 100 {
+		return fmt.Errorf("Receive tolerance %d%% is not in the range of 1 to 100", percent)
+	}
+	atomic.StoreInt32(&r.tolerance, int32(percent))
+	return nil
+}
+
+func (r *Receiver) loop() {
+	defer r.wg.Done()
+	prev, last := time.Now(), time.Now() // times of the last two edges
+	for {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		if !r.pin.WaitForEdge(edgeTimeout) {
+			continue
+		}
+		now := time.Now()
+		d := now.Sub(last)
+		if d < glitchLimit && r.changeCount > 0 {
+			// A glitch toggled the level and back: forget the edge that
+			// started it, so the level before it continues.
+			r.changeCount--
+			last = prev
+			continue
+		}
+		r.handle(d)
+		prev, last = last, now
+	}
+}
+
+// Handle the duration since the last level change.
+func (r *Receiver) handle(d time.Duration) {
+	if d > separationLimit {
+		// A long stretch without signal level change occurred. This could be
+		// the gap between two transmissions.
+		if r.repeatCount == 0 || diff(d, r.timings[0]) < 200*time.Microsecond {
+			// This long signal is close in length to the long signal which
+			// started the previously recorded timings; this suggests that it
+			// may indeed be a gap between two transmissions (we assume here
+			// that a sender will send the signal multiple times, with roughly
+			// the same gap between them). The first one is taken as such
+			// anyway, like upstream.
+			r.repeatCount++
+			if r.repeatCount == 2 {
+				if c, ok := r.decodeBest(); ok {
+					atomic.AddUint64(&r.decoded, 1)
+					r.deliver(c)
+				}
+				r.repeatCount = 0
+			}
+		}
+		r.changeCount = 0
+	}
+
+	// detect overflow
+	if r.changeCount >= maxChanges {
+		r.changeCount = 0
+		r.repeatCount = 0
+	}
+
+	r.timings[r.changeCount] = d
+	r.changeCount++
+}
+
+// Decode the recorded timings with all protocols and return the best match.
+// Protocols with similar waveforms (e.g., 1 and 4) often both decode the same
+// timings, upstream reports the first one. The best match is the one with the
+// smallest deviation from its nominal waveforms.
+func (r *Receiver) decodeBest() (ReceivedCode, bool) {
+	var best ReceivedCode
+	bestDeviation, found := 0.0, false
+	for i := range protocols {
+		if c, deviation, ok := r.decode(i); ok && (!found || deviation < bestDeviation) {
+			best, bestDeviation, found = c, deviation, true
+		}
+	}
+	return best, found
+}
+
+// Try to decode the recorded timings with protocols[p].
+// Returns the mean deviation of the timings from the waveforms of the protocol
+// in pulse lengths.
+func (r *Receiver) decode(p int) (ReceivedCode, float64, bool) {
+	prot := protocols[p]
+
+	syncLen := prot.syncBit.low
+	if prot.syncBit.high > syncLen {
+		syncLen = prot.syncBit.high
+	}
+	delay := r.timings[0] / time.Duration(syncLen)
+	tolerance := delay * time.Duration(atomic.LoadInt32(&r.tolerance)) / 100
+
+	first := 1
+	if prot.inverted {
+		first = 2
+	}
+
+	if delay == 0 {
+		return ReceivedCode{}, 0, false
+	}
+
+	var deviation time.Duration
+	matches := func(i int, w waveform) bool {
+		dh := diff(r.timings[i], delay*time.Duration(w.high))
+		dl := diff(r.timings[i+1], delay*time.Duration(w.low))
+		if dh < tolerance && dl < tolerance {
+			deviation += dh + dl
+			return true
+		}
+		return false
+	}
+
+	var code uint64
+	for i := first; i < r.changeCount-1; i += 2 {
+		code <<= 1
+		if matches(i, prot.zeroBit) {
+			// zero
+		} else if matches(i, prot.oneBit) {
+			code |= 1
+		} else {
+			return ReceivedCode{}, 0, false
+		}
+	}
+
+	// ignore very short transmissions: no device sends them, so this must be noise
+	if r.changeCount <= 7 {
+		return ReceivedCode{}, 0, false
+	}
+
+	return ReceivedCode{
+		Value:       code,
+		BitLength:   (r.changeCount - 1) / 2,
+		Protocol:    p + 1,
+		PulseLength: delay,
+	}, float64(deviation) / float64(delay) / float64(r.changeCount-first), true
+}
+
+func (r *Receiver) deliver(c ReceivedCode) {
+	if r.accept(c) {
+		select {
+		case r.codes <- c:
+		default:
+		}
+	}
+
+	r.listenersMu.Lock()
+	defer r.listenersMu.Unlock()
+	for l := range r.listeners {
+		select {
+		case l <- c:
+		default:
+		}
+	}
+}
+
+// Returns a channel that gets every received code in addition to Codes,
+// unfiltered, until cancel is called.
+func (r *Receiver) listen() (codes <-chan ReceivedCode, cancel func()) {
+	l := make(chan ReceivedCode, 16)
+	r.listenersMu.Lock()
+	defer r.listenersMu.Unlock()
+	if r.listeners == nil {
+		r.listeners = make(map[chan ReceivedCode]bool)
+	}
+	r.listeners[l] = true
+	return l, func() {
+		r.listenersMu.Lock()
+		delete(r.listeners, l)
+		r.listenersMu.Unlock()
+	}
+}
+
+func diff(a, b time.Duration) time.Duration {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
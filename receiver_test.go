@@ -0,0 +1,52 @@
+package rcswitch
+
+import (
+	"testing"
+	"time"
+)
+
+// Feed the timings of a frame of protocol 1 to r: the bits, followed by the
+// high period of the sync bit and its low period, the gap.
+func feedFrame(r *Receiver, code string, gap time.Duration) {
+	const pulse = 350 * time.Microsecond
+	for _, b := range code {
+		if b == '1' {
+			r.handle(3 * pulse)
+			r.handle(pulse)
+		} else {
+			r.handle(pulse)
+			r.handle(3 * pulse)
+		}
+	}
+	r.handle(pulse)
+	r.handle(gap)
+}
+
+func TestReceiverHandle(t *testing.T) {
+	const code = "000000000001010100010001"
+	const gap = 31 * 350 * time.Microsecond
+
+	r := &Receiver{codes: make(chan ReceivedCode, 16), tolerance: 60}
+	r.handle(gap)
+	feedFrame(r, code, gap)
+	// like upstream, the first gap counts as the start of a transmission,
+	// so one frame between two gaps is decoded
+	if r.Decoded() != 1 {
+		t.Fatalf("Decoded %d codes after one frame, expected 1", r.Decoded())
+	}
+	want := ReceivedCode{Value: 0x001511, BitLength: 24, Protocol: 1, PulseLength: 350 * time.Microsecond}
+	if c := <-r.codes; c != want {
+		t.Errorf("Received %+v, expected %+v", c, want)
+	}
+
+	// a gap of a different length does not count as a repeat
+	r.handle(gap / 2)
+	feedFrame(r, code, gap)
+	if r.Decoded() != 1 {
+		t.Errorf("Decoded %d codes after a frame between different gaps, expected 1", r.Decoded())
+	}
+	feedFrame(r, code, gap)
+	if r.Decoded() != 2 {
+		t.Errorf("Decoded %d codes after a frame between equal gaps, expected 2", r.Decoded())
+	}
+}
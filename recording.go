@@ -0,0 +1,106 @@
+package rcswitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+// A Recording is a raw signal captured by Record: alternating high and low
+// durations, starting with high. It can be replayed by Replay.
+type Recording struct {
+	Pulses []time.Duration
+}
+
+// On disk, pulses are stored in microseconds.
+type recordingFile struct {
+	Pulses []int64 `json:"pulses_us"`
+}
+
+// Capture a raw signal from a receiver module connected to pin.
+// Recording starts with the first rising edge and stops after there was no
+// level change for silence, or when ctx is done. Cheap receivers output noise
+// when there is no signal, so silence might never be seen, use a ctx with a
+// timeout then. The pin can not be used by a Receiver at the same time.
+func Record(ctx context.Context, pin gpio.PinIO, silence time.Duration) (Recording, error) {
+	if err := pin.In(gpio.Float, gpio.BothEdges); err != nil {
+		return Recording{}, err
+	}
+	defer pin.In(gpio.Float, gpio.NoEdge)
+
+	var rec Recording
+	var last time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			if len(rec.Pulses) == 0 {
+				return rec, ctx.Err()
+			}
+			return rec, nil
+		default:
+		}
+
+		timeout := edgeTimeout
+		if !last.IsZero() && silence < timeout {
+			timeout = silence
+		}
+		if !pin.WaitForEdge(timeout) {
+			if !last.IsZero() && time.Since(last) >= silence {
+				return rec, nil
+			}
+			continue
+		}
+
+		now := time.Now()
+		if last.IsZero() {
+			if pin.Read() == gpio.High {
+				last = now
+			}
+			continue
+		}
+		rec.Pulses = append(rec.Pulses, now.Sub(last))
+		last = now
+	}
+}
+
+// Replay a recording, see SendRaw.
+func (s *RCSwitch) Replay(rec Recording) error {
+	return s.SendRaw(rec.Pulses)
+}
+
+// Save the recording to a file.
+func (rec Recording) Save(path string) error {
+	f := recordingFile{Pulses: make([]int64, len(rec.Pulses))}
+	for i, d := range rec.Pulses {
+		f.Pulses[i] = d.Microseconds()
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// Load a recording saved by Save.
+func LoadRecording(path string) (Recording, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Recording{}, err
+	}
+	var f recordingFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return Recording{}, err
+	}
+	if len(f.Pulses) == 0 {
+		return Recording{}, errors.New("Recording does not contain any pulses")
+	}
+	rec := Recording{Pulses: make([]time.Duration, len(f.Pulses))}
+	for i, us := range f.Pulses {
+		rec.Pulses[i] = time.Duration(us) * time.Microsecond
+	}
+	return rec, nil
+}
@@ -0,0 +1,94 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Switch identifies a single switch, format is the same as for SwitchOn.
+type Switch struct {
+	Family, Group, Device string
+}
+
+// Periodically re-send the tracked state (see IsOn) of the given switches to
+// heal frames that got lost. Switches that were never switched by this object
+// are skipped. Every interval, randomly shifted by up to ±jitter to avoid
+// synchronized bursts with other transmitters, the switches are sent one by one
+// with the protocol and repeat count of the command that last switched them
+// (see WithProtocol and WithRepeat).
+// Other commands can be interleaved between them.
+// Refresh blocks until ctx is done or the RCSwitch object is closed, so it is
+// usually run in its own goroutine. Failed transmissions do not stop Refresh,
+// they show up in PinHealth and Metrics. Refreshes are not recorded in the history.
+func (s *RCSwitch) Refresh(ctx context.Context, switches []Switch, interval, jitter time.Duration) error {
+	if interval <= 0 || jitter < 0 || jitter >= interval {
+		return errors.New("Interval has to be positive and jitter within 0 and interval")
+	}
+	s.Lock()
+	for _, sw := range switches {
+		if _, err := s.codeWord(sw.Family, sw.Group, sw.Device, true); err != nil {
+			s.Unlock()
+			return err
+		}
+	}
+	s.Unlock()
+
+	for {
+		d := interval
+		if jitter > 0 {
+			d += time.Duration(rand.Int63n(int64(2*jitter))) - jitter
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-s.done:
+			t.Stop()
+			return ErrClosed
+		case <-t.C:
+		}
+
+		for _, sw := range switches {
+			if err := s.refresh(sw); err == ErrClosed {
+				return err
+			}
+		}
+	}
+}
+
+func (s *RCSwitch) refresh(sw Switch) error {
+	s.Lock()
+	defer s.Unlock()
+	on, ok := s.isOn[sw.Group+sw.Device]
+	if !ok {
+		return nil
+	}
+	cmd, ok := s.commands[sw]
+	if !ok {
+		cmd = Command{Family: sw.Family, Group: sw.Group, Device: sw.Device}
+	}
+	cmd.On = on
+	code, err := s.codeWord(cmd.Family, cmd.Group, cmd.Device, on)
+	if err != nil {
+		return err
+	}
+	prot, _, nrRepeat, err := s.commandProtocol(cmd)
+	if err != nil {
+		return err
+	}
+	return s.sendRepeat(context.Background(), triStateToBinary(code), prot, nrRepeat)
+}
+
+// Remember cmd for Refresh. Has to be called with s locked.
+func (s *RCSwitch) setCommand(cmd Command) {
+	if s.stateless {
+		return
+	}
+	if s.commands == nil {
+		s.commands = make(map[Switch]Command)
+	}
+	s.commands[Switch{Family: cmd.Family, Group: cmd.Group, Device: cmd.Device}] = cmd
+}
@@ -0,0 +1,180 @@
+package rcswitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A Device is a named switch, see DeviceRegistry.
+// Format of Family, Group, and Device is the same as for SwitchOn.
+type Device struct {
+	Name     string `json:"name" yaml:"name"`
+	Family   string `json:"family,omitempty" yaml:"family,omitempty"`
+	Group    string `json:"group" yaml:"group"`
+	Device   string `json:"device" yaml:"device"`
+	Protocol int    `json:"protocol,omitempty" yaml:"protocol,omitempty"` // 0 uses the protocol set by SetProtocol
+	Repeat   int    `json:"repeat,omitempty" yaml:"repeat,omitempty"`     // 0 uses the repeat set by SetRepeat
+	// Carrier frequency in MHz, 0 uses the frequency set by SetFrequency.
+	Frequency float64 `json:"frequency,omitempty" yaml:"frequency,omitempty"`
+}
+
+// DeviceRegistry maps names to devices, so they can be switched by name with
+// On and Off (see SetDeviceRegistry). A registry can be shared between
+// multiple RCSwitch objects.
+type DeviceRegistry struct {
+	devices map[string]Device
+	sync.Mutex
+}
+
+// Create an empty DeviceRegistry.
+func NewDeviceRegistry() *DeviceRegistry {
+	return &DeviceRegistry{devices: make(map[string]Device)}
+}
+
+// Load a DeviceRegistry from a JSON (.json) or YAML (.yaml, .yml) file
+// containing a list of devices, e.g.:
+//
+//	# devices.yaml
+//	- name: kitchen_lamp
+//	  group: "11011"
+//	  device: "10000"
+//	- name: garden
+//	  family: b
+//	  group: "1"
+//	  device: "2"
+//	  protocol: 2
+//	  repeat: 15
+//	- name: garage
+//	  group: "10000"
+//	  device: "01000"
+//	  frequency: 315
+func LoadDeviceRegistry(path string) (*DeviceRegistry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []Device
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(b, &devices)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &devices)
+	default:
+		return nil, fmt.Errorf("Device file %s has to end in .json, .yaml, or .yml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	r := NewDeviceRegistry()
+	for _, d := range devices {
+		if err := r.Register(d); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return r, nil
+}
+
+// Add a device, replacing a device with the same name.
+func (r *DeviceRegistry) Register(d Device) error {
+	if d.Name == "" {
+		return errors.New("Device name must not be empty")
+	}
+	if d.Protocol < 0 || d.Protocol > len(protocols) {
+		return fmt.Errorf("Protocol %d of device %s is not supported, supported are 1 to %d", d.Protocol, d.Name, len(protocols))
+	}
+	if d.Repeat < 0 {
+		return fmt.Errorf("Repeat of device %s must not be negative", d.Name)
+	}
+	if _, err := megaHertz(d.Frequency); err != nil {
+		return fmt.Errorf("Device %s: %v", d.Name, err)
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.devices[d.Name] = d
+	return nil
+}
+
+// Returns the device with the given name.
+func (r *DeviceRegistry) Lookup(name string) (Device, bool) {
+	r.Lock()
+	defer r.Unlock()
+	d, ok := r.devices[name]
+	return d, ok
+}
+
+// Returns all devices, sorted by name.
+func (r *DeviceRegistry) Devices() []Device {
+	r.Lock()
+	defer r.Unlock()
+	devices := make([]Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name < devices[j].Name })
+	return devices
+}
+
+// Set the registry used by On and Off, nil removes it.
+func (s *RCSwitch) SetDeviceRegistry(r *DeviceRegistry) {
+	s.Lock()
+	s.registry = r
+	s.Unlock()
+}
+
+// Turn on a device of the registry set by SetDeviceRegistry by name.
+// The device is sent with its own protocol, repeat, and frequency, if it has one.
+func (s *RCSwitch) On(name string) error {
+	return s.switchDevice(name, true)
+}
+
+// Turn off a device by name, see On.
+func (s *RCSwitch) Off(name string) error {
+	return s.switchDevice(name, false)
+}
+
+// Turn on a device by name and turn it off again after d, see On and SwitchOnFor.
+func (s *RCSwitch) OnFor(name string, d time.Duration) error {
+	dev, err := s.lookupDevice(name)
+	if err != nil {
+		return err
+	}
+	return s.switchOnFor(dev.command(true), d)
+}
+
+func (s *RCSwitch) switchDevice(name string, on bool) error {
+	d, err := s.lookupDevice(name)
+	if err != nil {
+		return err
+	}
+	return s.switchCoalesced(context.Background(), d.command(on))
+}
+
+func (s *RCSwitch) lookupDevice(name string) (Device, error) {
+	s.Lock()
+	r := s.registry
+	s.Unlock()
+	if r == nil {
+		return Device{}, errors.New("No device registry set")
+	}
+	d, ok := r.Lookup(name)
+	if !ok {
+		return Device{}, fmt.Errorf("Device %s is not registered", name)
+	}
+	return d, nil
+}
+
+func (d Device) command(on bool) Command {
+	return Command{Family: d.Family, Group: d.Group, Device: d.Device, On: on, Protocol: d.Protocol, Repeat: d.Repeat, Frequency: d.Frequency}
+}
@@ -0,0 +1,68 @@
+package rcswitch
+
+import (
+	"fmt"
+	"time"
+)
+
+// TransmissionReport describes how a single transmission went, see
+// LastTransmission.
+type TransmissionReport struct {
+	PulseLength time.Duration
+	Frames      int           // number of frames sent completely
+	Expected    time.Duration // nominal duration of the transmission
+	Duration    time.Duration // measured duration of the transmission
+	// Longest time a single pulse took longer than it should, only measured by
+	// the GPIO transmitter (see SetPin), 0 otherwise.
+	MaxOvershoot time.Duration
+	Err          error // the error of the transmission
+	DryRun       bool  // nothing was sent, see SetDryRun
+}
+
+// ReportSink can additionally be implemented by a MetricsSink to be notified
+// about the report of every transmission. The same restrictions as for
+// ObserveSend apply.
+type ReportSink interface {
+	ObserveTransmission(r TransmissionReport)
+}
+
+// Returns an error if OS jitter likely corrupted the transmission, i.e., if a
+// single pulse was off by more than 30% of the pulse length, like
+// TimingProbe.Warning. Returns nil otherwise. Retrying usually helps.
+func (r TransmissionReport) Warning() error {
+	if float64(r.MaxOvershoot) > probeMaxError*float64(r.PulseLength) {
+		return fmt.Errorf("A pulse took %s longer than its nominal length, which is too much for a pulse length of %s, the transmission was likely corrupted",
+			r.MaxOvershoot, r.PulseLength)
+	}
+	return nil
+}
+
+// Returns the report of the last transmission of the RCSwitch object, e.g.,
+// right after SendTriState returned. With concurrent callers it might be the
+// report of another transmission, use a ReportSink then.
+func (s *RCSwitch) LastTransmission() TransmissionReport {
+	s.Lock()
+	defer s.Unlock()
+	return s.lastReport
+}
+
+// Record the report of a transmission. Has to be called with s locked.
+func (s *RCSwitch) report(r TransmissionReport) {
+	s.lastReport = r
+	if sink, ok := s.metrics.sink.(ReportSink); ok {
+		sink.ObserveTransmission(r)
+	}
+}
+
+// Returns the nominal duration of the transmission.
+func (tr Transmission) duration() time.Duration {
+	pulses := 2 * tr.Preamble
+	for _, w := range tr.Frame {
+		pulses += (w.High + w.Low) * tr.Repeat
+	}
+	d := time.Duration(pulses) * tr.PulseLength
+	if tr.Repeat > 1 {
+		d += time.Duration(tr.Repeat-1) * tr.Gap
+	}
+	return d
+}
@@ -0,0 +1,106 @@
+package rcswitch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Switch a device of the registry (see SetDeviceRegistry) on or off at the
+// times given by when:
+//
+//	"22:30"                        every day at 22:30 local time
+//	"@every 1h30m"                 every 90 minutes, starting 90 minutes from now
+//	"2021-03-01T07:00:00+01:00"    once, RFC 3339
+//
+// Scheduled commands run until cancel is called or the RCSwitch object is
+// closed. Failed commands are not retried, they show up in PinHealth and
+// Metrics.
+func (s *RCSwitch) Schedule(device string, on bool, when string) (cancel func(), err error) {
+	next, err := parseSchedule(when)
+	if err != nil {
+		return nil, err
+	}
+	s.Lock()
+	r := s.registry
+	s.Unlock()
+	if r == nil {
+		return nil, errors.New("No device registry set")
+	}
+	if _, ok := r.Lookup(device); !ok {
+		return nil, fmt.Errorf("Device %s is not registered", device)
+	}
+
+	first := next(time.Now())
+	if first.IsZero() {
+		return nil, fmt.Errorf("Schedule %q is in the past", when)
+	}
+	return s.runAt(first, next, func() error { return s.switchDevice(device, on) }), nil
+}
+
+// Call f at first and then at the times returned by next, until next returns
+// the zero time, f returns ErrClosed, cancel is called, or s is closed. Times
+// in the past are due right away.
+func (s *RCSwitch) runAt(first time.Time, next func(time.Time) time.Time, f func() error) (cancel func()) {
+	stop := make(chan struct{})
+	go func() {
+		for t := first; !t.IsZero(); t = next(t) {
+			timer := time.NewTimer(time.Until(t))
+			select {
+			case <-timer.C:
+			case <-stop:
+				timer.Stop()
+				return
+			case <-s.done:
+				timer.Stop()
+				return
+			}
+			if err := f(); err == ErrClosed {
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(stop) }) }
+}
+
+// Parse when of Schedule into a function returning the next time after the
+// given one, or the zero time if there is none.
+func parseSchedule(when string) (func(time.Time) time.Time, error) {
+	when = strings.TrimSpace(when)
+
+	if strings.HasPrefix(when, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(when, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("Schedule %q: %v", when, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("Schedule %q: interval has to be positive", when)
+		}
+		return func(t time.Time) time.Time { return t.Add(d) }, nil
+	}
+
+	if clock, err := time.Parse("15:04", when); err == nil {
+		return func(t time.Time) time.Time {
+			next := time.Date(t.Year(), t.Month(), t.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+			if !next.After(t) {
+				next = time.Date(t.Year(), t.Month(), t.Day()+1, clock.Hour(), clock.Minute(), 0, 0, time.Local)
+			}
+			return next
+		}, nil
+	}
+
+	if at, err := time.Parse(time.RFC3339, when); err == nil {
+		return func(t time.Time) time.Time {
+			if at.After(t) {
+				return at
+			}
+			return time.Time{}
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Schedule %q is neither a time of day (15:04), an interval (@every 1h), nor a time (RFC 3339)", when)
+}
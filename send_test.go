@@ -0,0 +1,53 @@
+package rcswitch_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rck/rcswitch"
+	"github.com/rck/rcswitch/rcswitchtest"
+
+	"periph.io/x/periph/conn/gpio"
+)
+
+func TestRepeatGapInverted(t *testing.T) {
+	const gap = 100 * time.Millisecond
+	pin := rcswitchtest.NewPin("GPIO17")
+	rc := rcswitch.NewRCSwitch(pin)
+	if err := rc.SetProtocol(6); err != nil {
+		t.Fatal(err)
+	}
+	rc.SetRepeat(2)
+	if err := rc.SetRepeatGap(gap); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.SendBinary("0101"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The gap is by far the longest period, the carrier has to be off.
+	var longest rcswitchtest.Pulse
+	for _, p := range pin.Pulses() {
+		if p.Duration > longest.Duration {
+			longest = p
+		}
+	}
+	if longest.Duration < gap {
+		t.Fatalf("Longest period is %v, expected at least the gap of %v", longest.Duration, gap)
+	}
+	if longest.Level != gpio.Low {
+		t.Errorf("Pin is %v during the gap, expected %v", longest.Level, gpio.Low)
+	}
+}
+
+func TestSendOverflow(t *testing.T) {
+	rc := rcswitch.NewRCSwitch(rcswitchtest.NewPin("GPIO17"))
+	for _, tt := range []struct {
+		code      uint64
+		bitLength int
+	}{{1 << 36, 36}, {1 << 33, 33}, {1, 65}} {
+		if err := rc.Send(tt.code, tt.bitLength); err == nil {
+			t.Errorf("Send(%d, %d) does not fail", tt.code, tt.bitLength)
+		}
+	}
+}
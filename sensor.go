@@ -0,0 +1,169 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Frames of the same sensor and status received within this time are one event.
+const sensorRepeat = 3 * time.Second
+
+// SensorEventType classifies a frame of a Sensor.
+type SensorEventType int
+
+const (
+	SensorUnknown    SensorEventType = iota // status nibble not listed for the sensor
+	SensorAlarm                             // smoke, water, ...
+	SensorHeartbeat                         // the sensor is alive and fine
+	SensorLowBattery                        // the battery has to be replaced soon
+)
+
+func (t SensorEventType) String() string {
+	switch t {
+	case SensorAlarm:
+		return "alarm"
+	case SensorHeartbeat:
+		return "heartbeat"
+	case SensorLowBattery:
+		return "low battery"
+	}
+	return "unknown"
+}
+
+// A Sensor is a 433MHz smoke detector, water leak detector, or similar
+// sensor sending EV1527 frames: 24 bits, a 20 bit address unique to the
+// sensor followed by a 4 bit status nibble. What the nibbles mean differs
+// between models, they are easiest found by triggering the sensor, pressing
+// its test button, and pulling its battery while running sniff. The address
+// is the code shown by sniff shifted right by 4 bits.
+type Sensor struct {
+	Name       string
+	Address    uint32  // 20 bits
+	Protocol   int     // 0 accepts all protocols, most sensors use protocol 1
+	Alarm      []uint8 // status nibbles of alarm frames
+	Heartbeat  []uint8 // status nibbles of the periodic "I am alive" frames
+	LowBattery []uint8 // status nibbles of low battery warnings
+}
+
+// A SensorEvent is a frame of a Sensor, see WatchSensors.
+type SensorEvent struct {
+	Sensor string // name of the sensor
+	Type   SensorEventType
+	Status uint8 // status nibble
+	Time   time.Time
+}
+
+// Split c into address and status nibble, if it is an EV1527 frame.
+func DecodeEV1527(c ReceivedCode) (address uint32, status uint8, ok bool) {
+	if c.BitLength != 24 {
+		return 0, 0, false
+	}
+	return uint32(c.Value >> 4), uint8(c.Value & 0xf), true
+}
+
+// Returns the event c is a frame of, if it was sent by the sensor.
+func (s Sensor) Decode(c ReceivedCode) (SensorEvent, bool) {
+	address, status, ok := DecodeEV1527(c)
+	if !ok || address != s.Address || (s.Protocol != 0 && s.Protocol != c.Protocol) {
+		return SensorEvent{}, false
+	}
+	e := SensorEvent{Sensor: s.Name, Status: status}
+	switch {
+	case containsNibble(s.Alarm, status):
+		e.Type = SensorAlarm
+	case containsNibble(s.Heartbeat, status):
+		e.Type = SensorHeartbeat
+	case containsNibble(s.LowBattery, status):
+		e.Type = SensorLowBattery
+	}
+	return e, true
+}
+
+func (s Sensor) check() error {
+	if s.Address>>20 != 0 {
+		return fmt.Errorf("Address 0x%x of sensor %s has more than 20 bits", s.Address, s.Name)
+	}
+	if s.Protocol < 0 || s.Protocol > len(protocols) {
+		return fmt.Errorf("Protocol %d of sensor %s is not supported, supported are 1 to %d", s.Protocol, s.Name, len(protocols))
+	}
+	for _, list := range [][]uint8{s.Alarm, s.Heartbeat, s.LowBattery} {
+		for _, n := range list {
+			if n > 0xf {
+				return fmt.Errorf("Status 0x%x of sensor %s is not a nibble", n, s.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Call event for every frame received by the Receiver from one of the
+// sensors, so alarms can trigger automations. Sensors repeat their frames
+// many times, frames of the same sensor and status within a few seconds are
+// reported once. Frames with a status not listed for the sensor are reported
+// as SensorUnknown. event is called from the goroutine of WatchSensors and
+// should not block for long. WatchSensors blocks until ctx is done or the
+// Receiver is closed, so it is usually run in its own goroutine. Codes are
+// still delivered by Codes.
+func (r *Receiver) WatchSensors(ctx context.Context, sensors []Sensor, event func(SensorEvent)) error {
+	if len(sensors) == 0 {
+		return errors.New("No sensors to watch")
+	}
+	for _, s := range sensors {
+		if err := s.check(); err != nil {
+			return err
+		}
+	}
+	codes, cancel := r.listen()
+	defer cancel()
+
+	w := sensorWatcher{sensors: sensors}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.done:
+			return errors.New("Receiver is closed")
+		case c := <-codes:
+			if e, ok := w.handle(c, time.Now()); ok {
+				event(e)
+			}
+		}
+	}
+}
+
+// Decodes received codes for WatchSensors and drops repeated frames.
+type sensorWatcher struct {
+	sensors []Sensor
+	last    map[uint32]SensorEvent // per sensor address
+}
+
+func (w *sensorWatcher) handle(c ReceivedCode, now time.Time) (SensorEvent, bool) {
+	for _, s := range w.sensors {
+		e, ok := s.Decode(c)
+		if !ok {
+			continue
+		}
+		e.Time = now
+		last, seen := w.last[s.Address]
+		if w.last == nil {
+			w.last = make(map[uint32]SensorEvent)
+		}
+		w.last[s.Address] = e // every repeat restarts the window
+		if seen && last.Status == e.Status && now.Sub(last.Time) < sensorRepeat {
+			return SensorEvent{}, false
+		}
+		return e, true
+	}
+	return SensorEvent{}, false
+}
+
+func containsNibble(list []uint8, v uint8) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
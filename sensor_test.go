@@ -0,0 +1,47 @@
+package rcswitch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSensorWatcher(t *testing.T) {
+	smoke := Sensor{Name: "smoke", Address: 0x5a5a5, Protocol: 1, Alarm: []uint8{0xa}, Heartbeat: []uint8{0x6}, LowBattery: []uint8{0xe}}
+	if err := smoke.check(); err != nil {
+		t.Fatal(err)
+	}
+	w := sensorWatcher{sensors: []Sensor{smoke}}
+	start := time.Date(2021, 3, 1, 7, 0, 0, 0, time.UTC)
+
+	frames := []struct {
+		value    uint64
+		bits     int
+		protocol int
+		after    time.Duration // since start
+		want     SensorEventType
+		reported bool
+	}{
+		{0x5a5a5a, 24, 1, 0, SensorAlarm, true},
+		{0x5a5a5a, 24, 1, time.Second, SensorAlarm, false}, // repeat
+		{0x5a5a5a, 24, 1, 2 * time.Second, SensorAlarm, false},
+		{0x5a5a56, 24, 1, 3 * time.Second, SensorHeartbeat, true},
+		{0x5a5a5e, 24, 1, 4 * time.Second, SensorLowBattery, true},
+		{0x5a5a53, 24, 1, 5 * time.Second, SensorUnknown, true},
+		{0x5a5a53, 24, 1, 9 * time.Second, SensorUnknown, true}, // after the window
+		{0x5a5a5a, 24, 2, 10 * time.Second, 0, false},           // other protocol
+		{0x15a5a5a, 25, 1, 11 * time.Second, 0, false},          // not EV1527
+		{0x4a5a5a, 24, 1, 12 * time.Second, 0, false},           // other sensor
+	}
+	for i, f := range frames {
+		e, ok := w.handle(ReceivedCode{Value: f.value, BitLength: f.bits, Protocol: f.protocol}, start.Add(f.after))
+		if ok != f.reported || (ok && (e.Type != f.want || e.Sensor != "smoke" || e.Status != uint8(f.value&0xf))) {
+			t.Errorf("Frame %d: got %+v, %v, expected %v, %v", i, e, ok, f.want, f.reported)
+		}
+	}
+
+	for _, s := range []Sensor{{Address: 1 << 20}, {Protocol: 13}, {Alarm: []uint8{0x10}}} {
+		if err := s.check(); err == nil {
+			t.Errorf("Sensor %+v was accepted", s)
+		}
+	}
+}
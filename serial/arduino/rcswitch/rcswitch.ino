@@ -0,0 +1,111 @@
+// Firmware for the serial transmitter backend of github.com/rck/rcswitch,
+// see the documentation of the serial package for the protocol.
+// Connect the data pin of a 433MHz transmitter to TX_PIN.
+
+#define TX_PIN 10
+#define BAUD 115200
+#define MAX_LINE 512
+#define MAX_WAVEFORMS 128
+
+static char line[MAX_LINE];
+static int lineLen;
+
+static unsigned int highs[MAX_WAVEFORMS], lows[MAX_WAVEFORMS];
+static bool aborted;
+
+// Wait for d microseconds, delayMicroseconds is only accurate up to 16383.
+static void wait(unsigned long d) {
+  unsigned long start = micros();
+  while (micros() - start < d) {
+  }
+}
+
+// Read pending input between frames, an X aborts the transmission.
+static void poll() {
+  while (Serial.available()) {
+    if (Serial.read() == 'X') {
+      aborted = true;
+    }
+  }
+}
+
+static void transmit(char *args) {
+  unsigned long pulse, gap;
+  int inv, pre, repeat, n;
+  int consumed;
+  if (sscanf(args, "%lu %d %d %d %lu %n", &pulse, &inv, &pre, &repeat, &gap, &consumed) != 5) {
+    Serial.println("ERR malformed command");
+    return;
+  }
+  char *frame = args + consumed;
+  for (n = 0; *frame && n < MAX_WAVEFORMS; n++) {
+    highs[n] = strtoul(frame, &frame, 10);
+    if (*frame++ != ':') {
+      Serial.println("ERR malformed frame");
+      return;
+    }
+    lows[n] = strtoul(frame, &frame, 10);
+    if (*frame == ',') {
+      frame++;
+    }
+  }
+  if (*frame) {
+    Serial.println("ERR frame too long");
+    return;
+  }
+
+  int high = inv ? LOW : HIGH, low = inv ? HIGH : LOW;
+  aborted = false;
+  for (int i = 0; i < pre; i++) {
+    digitalWrite(TX_PIN, high);
+    wait(pulse);
+    digitalWrite(TX_PIN, low);
+    wait(pulse);
+  }
+  for (int r = 0; r < repeat && !aborted; r++) {
+    for (int i = 0; i < n; i++) {
+      digitalWrite(TX_PIN, high);
+      wait(highs[i] * pulse);
+      digitalWrite(TX_PIN, low);
+      wait(lows[i] * pulse);
+    }
+    digitalWrite(TX_PIN, LOW);
+    wait(gap);
+    poll();
+  }
+  digitalWrite(TX_PIN, LOW);
+  Serial.println(aborted ? "ERR aborted" : "OK");
+}
+
+void setup() {
+  pinMode(TX_PIN, OUTPUT);
+  digitalWrite(TX_PIN, LOW);
+  Serial.begin(BAUD);
+}
+
+void loop() {
+  while (Serial.available()) {
+    char c = Serial.read();
+    if (c != '\n') {
+      if (lineLen < MAX_LINE - 1) {
+        line[lineLen++] = c;
+      }
+      continue;
+    }
+    line[lineLen] = 0;
+    lineLen = 0;
+    switch (line[0]) {
+      case '?':
+        Serial.println("OK");
+        break;
+      case 'T':
+        transmit(line + 1);
+        break;
+      case 'X': // nothing to abort
+      case 0:
+        break;
+      default:
+        Serial.println("ERR unknown command");
+    }
+  }
+}
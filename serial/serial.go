@@ -0,0 +1,165 @@
+// Package serial provides an rcswitch.Transmitter that hands transmissions to
+// a microcontroller (e.g., an Arduino with a 433MHz transmitter, or a USB RF
+// stick running the same firmware) over a serial port. The Go side computes
+// code words and waveforms, the microcontroller does the timing, so busy hosts
+// and PCs without GPIOs can send reliably:
+//
+//	tx, err := serial.New("/dev/ttyUSB0", 115200)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	rc := rcswitch.NewRCSwitch(nil)
+//	rc.SetTransmitter(tx)
+//
+// The protocol is line based ASCII, every line ends with "\n" and is at most
+// 512 bytes long. Every command is answered by a single line, "OK" on success
+// or "ERR" followed by a message:
+//
+//	?                                  ping, answered by OK
+//	T pulse inv pre repeat gap frame   transmit
+//	X                                  abort the running transmission
+//
+// For T, pulse is the pulse length and gap the additional low period between
+// frames, both in microseconds, inv is 1 if high and low are swapped and 0
+// otherwise, pre the number of 1:1 preamble pulses and repeat the number of
+// frames. frame lists the waveforms of a frame as high:low multiples of the
+// pulse length separated by commas, e.g., "1:31,1:3,3:1". The answer is sent
+// once the transmission is done. X stops a transmission after the current
+// frame, it is then answered by "ERR aborted", X itself is not answered.
+// The line has to be low when idle. The directory arduino contains a sketch
+// implementing the protocol.
+package serial
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rck/rcswitch"
+)
+
+// How long to wait for the device to answer the first ping. Most Arduinos
+// reset when the port is opened and need about 2 seconds to boot.
+const readyTimeout = 5 * time.Second
+
+// Additional time the device gets to answer, on top of the transmission time.
+const answerTimeout = time.Second
+
+// Transmitter sends transmissions via a microcontroller on a serial port.
+type Transmitter struct {
+	f *os.File
+	r *bufio.Reader
+	sync.Mutex
+}
+
+// Wait until the device answers a ping on the opened port f.
+func newTransmitter(f *os.File) (*Transmitter, error) {
+	t := &Transmitter{f: f, r: bufio.NewReader(f)}
+	deadline := time.Now().Add(readyTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := f.WriteString("?\n"); err != nil {
+			f.Close()
+			return nil, err
+		}
+		// a booting device may swallow pings or print garbage, so retry
+		if err := t.answer(time.Now().Add(250 * time.Millisecond)); err == nil {
+			t.drain()
+			return t, nil
+		}
+	}
+	f.Close()
+	return nil, fmt.Errorf("No answer from %s within %s", f.Name(), readyTimeout)
+}
+
+// Transmit implements rcswitch.Transmitter.
+// If ctx is done, the device is asked to stop after the current frame.
+func (t *Transmitter) Transmit(ctx context.Context, tr rcswitch.Transmission) error {
+	t.Lock()
+	defer t.Unlock()
+
+	frame := make([]string, len(tr.Frame))
+	for i, w := range tr.Frame {
+		frame[i] = fmt.Sprintf("%d:%d", w.High, w.Low)
+	}
+	inv := 0
+	if tr.Inverted {
+		inv = 1
+	}
+	cmd := fmt.Sprintf("T %d %d %d %d %d %s\n", tr.PulseLength/time.Microsecond, inv, tr.Preamble, tr.Repeat,
+		tr.Gap/time.Microsecond, strings.Join(frame, ","))
+	if len(cmd) > 512 {
+		return fmt.Errorf("Transmission of %d waveforms is too long for the serial protocol", len(tr.Frame))
+	}
+	if _, err := t.f.WriteString(cmd); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- t.answer(time.Now().Add(duration(tr) + answerTimeout))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		t.f.WriteString("X\n")
+		<-done // "ERR aborted", or OK if it was done already
+		return ctx.Err()
+	}
+}
+
+// Close closes the serial port.
+func (t *Transmitter) Close() error {
+	t.Lock()
+	defer t.Unlock()
+	return t.f.Close()
+}
+
+// Read an answer of the device until deadline, returns nil for "OK".
+func (t *Transmitter) answer(deadline time.Time) error {
+	t.f.SetReadDeadline(deadline) // not supported by all platforms, the device has to answer then
+	for {
+		line, err := t.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "OK":
+			return nil
+		case strings.HasPrefix(line, "ERR"):
+			msg := strings.TrimSpace(strings.TrimPrefix(line, "ERR"))
+			if msg == "" {
+				return errors.New("Device reported an error")
+			}
+			return fmt.Errorf("Device reported an error: %s", msg)
+		}
+		// anything else is output of a booting or chatty device
+	}
+}
+
+// Discard answers to earlier pings that arrive late.
+func (t *Transmitter) drain() {
+	if err := t.f.SetReadDeadline(time.Now().Add(250 * time.Millisecond)); err != nil {
+		return // reading would block forever
+	}
+	for {
+		if _, err := t.r.ReadString('\n'); err != nil {
+			return
+		}
+	}
+}
+
+// Returns the nominal duration of tr.
+func duration(tr rcswitch.Transmission) time.Duration {
+	pulses := 2 * tr.Preamble
+	for _, w := range tr.Frame {
+		pulses += (w.High + w.Low) * tr.Repeat
+	}
+	return time.Duration(pulses)*tr.PulseLength + time.Duration(tr.Repeat)*tr.Gap
+}
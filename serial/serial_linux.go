@@ -0,0 +1,71 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// CBAUD | CBAUDEX, the mask of the baud rate in Cflag, missing in package syscall.
+const cbaud = 0x100f
+
+var bauds = map[int]uint32{
+	9600:   syscall.B9600,
+	19200:  syscall.B19200,
+	38400:  syscall.B38400,
+	57600:  syscall.B57600,
+	115200: syscall.B115200,
+	230400: syscall.B230400,
+}
+
+// Open the serial port at path (e.g., "/dev/ttyUSB0" or "/dev/ttyACM0") with
+// the given baud rate, 8N1, and wait until the device answers. A baud rate of
+// 0 keeps the settings of the port, e.g., as set with stty.
+func New(path string, baud int) (*Transmitter, error) {
+	speed, ok := bauds[baud]
+	if !ok && baud != 0 {
+		return nil, fmt.Errorf("Baud rate %d is not supported, supported are 9600, 19200, 38400, 57600, 115200, and 230400", baud)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	if baud != 0 {
+		if err := setRaw(f, speed); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Configuring %s: %v", path, err)
+		}
+	}
+	return newTransmitter(f)
+}
+
+// Put the terminal into raw mode with 8 data bits, no parity, and one stop bit.
+func setRaw(f *os.File, speed uint32) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		var t syscall.Termios
+		if _, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&t))); errno != 0 {
+			return
+		}
+		t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+		t.Oflag &^= syscall.OPOST
+		t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+		t.Cflag &^= syscall.CSIZE | syscall.PARENB | syscall.CSTOPB | cbaud
+		t.Cflag |= syscall.CS8 | syscall.CREAD | syscall.CLOCAL | speed
+		t.Ispeed, t.Ospeed = speed, speed
+		t.Cc[syscall.VMIN], t.Cc[syscall.VTIME] = 1, 0
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCSETS, uintptr(unsafe.Pointer(&t)))
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
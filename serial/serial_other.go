@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package serial
+
+import (
+	"errors"
+	"os"
+)
+
+// Open the serial port at path and wait until the device answers. Setting the
+// baud rate is only supported on Linux, elsewhere configure the port (e.g.,
+// with stty) and pass a baud rate of 0.
+func New(path string, baud int) (*Transmitter, error) {
+	if baud != 0 {
+		return nil, errors.New("Setting the baud rate is only supported on Linux, configure the port and use a baud rate of 0")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return newTransmitter(f)
+}
@@ -0,0 +1,187 @@
+package spiradio
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+)
+
+// CC1101 registers, strobes, and status registers, see its data sheet.
+const (
+	ccIOCFG0   = 0x02
+	ccPKTCTRL1 = 0x07
+	ccPKTCTRL0 = 0x08
+	ccFREQ2    = 0x0d
+	ccMDMCFG4  = 0x10
+	ccMDMCFG3  = 0x11
+	ccMDMCFG2  = 0x12
+	ccMDMCFG1  = 0x13
+	ccMCSM0    = 0x18
+	ccAGCCTRL2 = 0x1b
+	ccFREND0   = 0x22
+	ccPATABLE  = 0x3e
+	ccFIFO     = 0x3f
+
+	ccSRES  = 0x30
+	ccSTX   = 0x35
+	ccSRX   = 0x34
+	ccSIDLE = 0x36
+	ccSPWD  = 0x39
+	ccSFRX  = 0x3a
+	ccSFTX  = 0x3b
+
+	ccMARCSTATE = 0x35
+	ccTXBYTES   = 0x3a
+
+	ccRead   = 0x80
+	ccBurst  = 0x40
+	ccXOSC   = 26000000 // Hz
+	ccFIFOSz = 64
+)
+
+type cc1101 struct {
+	conn spi.Conn
+}
+
+// Create a Transmitter for a CC1101 on conn (SPI mode 0, up to 6.5MHz) sending
+// on carrier frequency f, e.g., 433.92MHz. The module is reset and configured for OOK.
+func NewCC1101(conn spi.Conn, f physic.Frequency) (*Transmitter, error) {
+	return newTransmitter(&cc1101{conn: conn}, f)
+}
+
+func (c *cc1101) init(f physic.Frequency) error {
+	if !validFrequency(f) {
+		return errFrequency
+	}
+	if err := c.strobe(ccSRES); err != nil {
+		return err
+	}
+	time.Sleep(time.Millisecond) // reset takes a few hundred µs
+
+	regs := []struct{ addr, val byte }{
+		{ccPKTCTRL1, 0x00},
+		{ccMDMCFG2, 0x30}, // OOK, no preamble and sync word
+		{ccMDMCFG1, 0x00},
+		{ccMCSM0, 0x18},    // calibrate when leaving idle
+		{ccAGCCTRL2, 0x03}, // recommended for OOK
+		{ccFREND0, 0x11},   // OOK: 0 uses PATABLE[0], 1 uses PATABLE[1]
+	}
+	for _, r := range regs {
+		if err := c.write(r.addr, r.val); err != nil {
+			return err
+		}
+	}
+	if err := c.setFrequency(f); err != nil {
+		return err
+	}
+	return c.write(ccPATABLE, 0x00, 0xc0) // off, about 10dBm
+}
+
+// The synthesizer is calibrated when leaving idle, see MCSM0.
+func (c *cc1101) setFrequency(f physic.Frequency) error {
+	if !validFrequency(f) {
+		return errFrequency
+	}
+	freq := uint32(uint64(f/physic.Hertz) << 16 / ccXOSC)
+	return c.write(ccFREQ2, byte(freq>>16), byte(freq>>8), byte(freq))
+}
+
+// Data rate is (256+M)*2^E*XOSC/2^28 with M in MDMCFG3 and E in MDMCFG4.
+func (c *cc1101) setBitTime(d time.Duration) error {
+	rate := float64(time.Second) / float64(d)
+	for e := uint(0); e < 16; e++ {
+		m := rate*(1<<28)/(ccXOSC*float64(uint(1)<<e)) - 256
+		if m < 255.5 {
+			if m < -0.5 {
+				break
+			}
+			// channel bandwidth of 325kHz for receiving
+			if err := c.write(ccMDMCFG4, 0x50|byte(e)); err != nil {
+				return err
+			}
+			return c.write(ccMDMCFG3, byte(m+0.5))
+		}
+	}
+	return fmt.Errorf("Pulse length %s is out of the range of the CC1101", d)
+}
+
+func (c *cc1101) send(ctx context.Context, data []byte) error {
+	if err := c.write(ccPKTCTRL0, 0x02); err != nil { // FIFO, infinite length
+		return err
+	}
+	n := len(data)
+	if n > ccFIFOSz {
+		n = ccFIFOSz
+	}
+	if err := c.write(ccFIFO, data[:n]...); err != nil {
+		return err
+	}
+	if err := c.strobe(ccSTX); err != nil {
+		return err
+	}
+	level := func() (int, error) {
+		b, err := read(c.conn, ccTXBYTES|ccRead|ccBurst)
+		if b&0x80 != 0 { // underflow, the FIFO ran empty
+			return 0, err
+		}
+		return int(b & 0x7f), err
+	}
+	return fill(ctx, data[n:], ccFIFOSz, level, func(b []byte) error {
+		return c.write(ccFIFO, b...)
+	})
+}
+
+func (c *cc1101) receive() error {
+	if err := c.write(ccIOCFG0, 0x0d); err != nil { // serial data output
+		return err
+	}
+	if err := c.write(ccPKTCTRL0, 0x32); err != nil { // asynchronous serial mode
+		return err
+	}
+	return c.strobe(ccSRX)
+}
+
+func (c *cc1101) standby() error {
+	if err := c.strobe(ccSIDLE); err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		s, err := read(c.conn, ccMARCSTATE|ccRead|ccBurst)
+		if err != nil {
+			return err
+		}
+		if s&0x1f == 0x01 { // IDLE
+			break
+		}
+		if i == 100 {
+			return fmt.Errorf("CC1101 does not enter idle state, state is 0x%02x", s)
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+	if err := c.strobe(ccSFTX); err != nil {
+		return err
+	}
+	return c.strobe(ccSFRX)
+}
+
+func (c *cc1101) sleep() error {
+	if err := c.standby(); err != nil {
+		return err
+	}
+	return c.strobe(ccSPWD)
+}
+
+func (c *cc1101) strobe(s byte) error {
+	return c.conn.Tx([]byte{s}, nil)
+}
+
+// Write vals to consecutive registers starting at addr.
+func (c *cc1101) write(addr byte, vals ...byte) error {
+	if len(vals) > 1 {
+		addr |= ccBurst
+	}
+	return c.conn.Tx(append([]byte{addr}, vals...), nil)
+}
@@ -0,0 +1,191 @@
+package spiradio
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+)
+
+// RFM69 (SX1231) registers, see its data sheet.
+const (
+	rfFifo           = 0x00
+	rfOpMode         = 0x01
+	rfDataModul      = 0x02
+	rfBitrateMsb     = 0x03
+	rfFrfMsb         = 0x07
+	rfPaLevel        = 0x11
+	rfRxBw           = 0x19
+	rfIrqFlags1      = 0x27
+	rfIrqFlags2      = 0x28
+	rfPreambleMsb    = 0x2c
+	rfSyncConfig     = 0x2e
+	rfPacketConfig1  = 0x37
+	rfPayloadLength  = 0x38
+	rfFifoThresh     = 0x3c
+	rfTestPa1        = 0x5a
+	rfTestPa2        = 0x5c
+	rfWrite          = 0x80
+	rfModeSleep      = 0x00
+	rfModeStandby    = 0x04
+	rfModeTx         = 0x0c
+	rfModeRx         = 0x10
+	rfFXOSC          = 32000000 // Hz
+	rfFIFOSize       = 66
+	rfFIFOThreshold  = 33
+	rfDataPacketOOK  = 0x08 // packet mode, OOK
+	rfDataContinuous = 0x68 // continuous mode without bit synchronizer, OOK
+)
+
+type rfm69 struct {
+	conn      spi.Conn
+	highPower bool
+}
+
+// Create a Transmitter for an RFM69 on conn (SPI mode 0, up to 10MHz) sending
+// on carrier frequency f, e.g., 433.92MHz. highPower has to be set for the
+// high power modules (RFM69HW, RFM69HCW), which only transmit on their PA_BOOST pin.
+func NewRFM69(conn spi.Conn, f physic.Frequency, highPower bool) (*Transmitter, error) {
+	return newTransmitter(&rfm69{conn: conn, highPower: highPower}, f)
+}
+
+func (r *rfm69) init(f physic.Frequency) error {
+	if !validFrequency(f) {
+		return errFrequency
+	}
+	if v, err := read(r.conn, 0x10); err != nil { // RegVersion
+		return err
+	} else if v != 0x24 {
+		return fmt.Errorf("No RFM69 found, version register is 0x%02x instead of 0x24", v)
+	}
+
+	pa := byte(0x9f) // PA0, 13dBm
+	if r.highPower {
+		pa = 0x7f // PA1 and PA2, 17dBm
+	}
+	regs := []struct{ addr, val byte }{
+		{rfOpMode, rfModeStandby},
+		{rfDataModul, rfDataPacketOOK},
+		{rfPaLevel, pa},
+		{rfRxBw, 0x41},        // 125kHz
+		{rfPreambleMsb, 0x00}, // no preamble
+		{rfPreambleMsb + 1, 0x00},
+		{rfSyncConfig, 0x00}, // no sync word
+		{rfPacketConfig1, 0x00},
+		{rfPayloadLength, 0x00},                // unlimited length
+		{rfFifoThresh, 0x80 | rfFIFOThreshold}, // start sending when the FIFO is not empty
+	}
+	for _, reg := range regs {
+		if err := r.write(reg.addr, reg.val); err != nil {
+			return err
+		}
+	}
+	return r.setFrequency(f)
+}
+
+// The new frequency is taken over when writing the least significant byte.
+func (r *rfm69) setFrequency(f physic.Frequency) error {
+	if !validFrequency(f) {
+		return errFrequency
+	}
+	frf := uint32(uint64(f/physic.Hertz) << 19 / rfFXOSC)
+	return r.write(rfFrfMsb, byte(frf>>16), byte(frf>>8), byte(frf))
+}
+
+// Bit rate is FXOSC divided by RegBitrate.
+func (r *rfm69) setBitTime(d time.Duration) error {
+	div := (int64(d)*rfFXOSC + int64(time.Second)/2) / int64(time.Second)
+	if div < 1 || div > 0xffff {
+		return fmt.Errorf("Pulse length %s is out of the range of the RFM69", d)
+	}
+	return r.write(rfBitrateMsb, byte(div>>8), byte(div))
+}
+
+func (r *rfm69) send(ctx context.Context, data []byte) error {
+	if err := r.write(rfDataModul, rfDataPacketOOK); err != nil {
+		return err
+	}
+	if r.highPower { // high power settings for PA_BOOST
+		if err := r.write(rfTestPa1, 0x5d); err != nil {
+			return err
+		}
+		if err := r.write(rfTestPa2, 0x7c); err != nil {
+			return err
+		}
+	}
+	n := len(data)
+	if n > rfFIFOSize {
+		n = rfFIFOSize
+	}
+	if err := r.write(rfFifo, data[:n]...); err != nil {
+		return err
+	}
+	if err := r.write(rfOpMode, rfModeTx); err != nil {
+		return err
+	}
+	// The FIFO level can not be read, only whether it is above the threshold.
+	level := func() (int, error) {
+		flags, err := read(r.conn, rfIrqFlags2)
+		switch {
+		case flags&0x40 == 0: // FifoNotEmpty
+			return 0, err
+		case flags&0x20 == 0: // FifoLevel
+			return rfFIFOThreshold, err
+		}
+		return rfFIFOSize, err
+	}
+	return fill(ctx, data[n:], rfFIFOSize, level, func(b []byte) error {
+		return r.write(rfFifo, b...)
+	})
+}
+
+func (r *rfm69) receive() error {
+	if err := r.write(rfDataModul, rfDataContinuous); err != nil { // data on DIO2
+		return err
+	}
+	return r.write(rfOpMode, rfModeRx)
+}
+
+func (r *rfm69) standby() error {
+	if err := r.write(rfOpMode, rfModeStandby); err != nil {
+		return err
+	}
+	if err := r.write(rfIrqFlags2, 0x10); err != nil { // FifoOverrun, clears the FIFO
+		return err
+	}
+	if r.highPower { // the high power settings must not be used when receiving
+		if err := r.write(rfTestPa1, 0x55); err != nil {
+			return err
+		}
+		if err := r.write(rfTestPa2, 0x70); err != nil {
+			return err
+		}
+	}
+	for i := 0; ; i++ {
+		flags, err := read(r.conn, rfIrqFlags1)
+		if err != nil {
+			return err
+		}
+		if flags&0x80 != 0 { // ModeReady
+			return nil
+		}
+		if i == 100 {
+			return fmt.Errorf("RFM69 does not enter standby mode, flags are 0x%02x", flags)
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+}
+
+func (r *rfm69) sleep() error {
+	if err := r.standby(); err != nil {
+		return err
+	}
+	return r.write(rfOpMode, rfModeSleep)
+}
+
+// Write vals to consecutive registers starting at addr.
+func (r *rfm69) write(addr byte, vals ...byte) error {
+	return r.conn.Tx(append([]byte{addr | rfWrite}, vals...), nil)
+}
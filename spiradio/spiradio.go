@@ -0,0 +1,218 @@
+// Package spiradio provides an rcswitch.Transmitter for SPI connected
+// transceiver modules, the TI CC1101 and the HopeRF RFM69 (SX1231). The pulse
+// train is sent as OOK modulated packet through the FIFO of the chip, one bit
+// per pulse length, so the chip does all the timing and transmissions are not
+// affected by the load of the host:
+//
+//	p, err := spireg.Open("")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	conn, err := p.Connect(4*physic.MegaHertz, spi.Mode0, 8)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	tx, err := spiradio.NewCC1101(conn, 433920*physic.KiloHertz)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	rc := rcswitch.NewRCSwitch(nil)
+//	rc.SetTransmitter(tx)
+//
+// The same module can receive: after Receive the demodulated signal is output
+// on GDO0 of the CC1101 or DIO2 of the RFM69, pass the GPIO it is connected to
+// to rcswitch.NewReceiver. Transmissions interrupt receiving.
+//
+// Transmissions with a frequency (see rcswitch.SetFrequency and
+// rcswitch.WithFrequency) are sent on it, otherwise on the one passed to the
+// constructor, which is also the one received on.
+package spiradio
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+	"periph.io/x/periph/conn/spi"
+
+	"github.com/rck/rcswitch"
+)
+
+// How often the FIFO level is polled while transmitting.
+const pollInterval = time.Millisecond
+
+// The chip specific part of a Transmitter.
+type chip interface {
+	init(f physic.Frequency) error
+	setFrequency(f physic.Frequency) error
+	setBitTime(d time.Duration) error
+	// Send data through the FIFO and return once the last bit is on the air.
+	send(ctx context.Context, data []byte) error
+	receive() error
+	standby() error
+	sleep() error
+}
+
+// Transmitter sends transmissions with a transceiver module.
+type Transmitter struct {
+	chip      chip
+	base      physic.Frequency // set up by the constructor, used for receiving
+	frequency physic.Frequency // currently tuned to
+	receiving bool
+	sync.Mutex
+}
+
+func newTransmitter(c chip, f physic.Frequency) (*Transmitter, error) {
+	if err := c.init(f); err != nil {
+		return nil, err
+	}
+	return &Transmitter{chip: c, base: f, frequency: f}, nil
+}
+
+// Transmit implements rcswitch.Transmitter. The module is retuned to
+// tr.Frequency for the transmission, if it has one, so devices on different
+// frequencies can be switched with the same module.
+func (t *Transmitter) Transmit(ctx context.Context, tr rcswitch.Transmission) error {
+	t.Lock()
+	defer t.Unlock()
+
+	f := tr.Frequency
+	if f == 0 {
+		f = t.base
+	}
+	if err := t.chip.standby(); err != nil {
+		return err
+	}
+	if err := t.tune(f); err != nil {
+		return err
+	}
+	if err := t.chip.setBitTime(tr.PulseLength); err != nil {
+		return err
+	}
+	err := t.chip.send(ctx, bits(tr))
+	if serr := t.chip.standby(); err == nil {
+		err = serr
+	}
+	if t.receiving {
+		if terr := t.tune(t.base); err == nil {
+			err = terr
+		}
+		if rerr := t.chip.receive(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// Change the carrier frequency, if it differs. Has to be called in standby.
+func (t *Transmitter) tune(f physic.Frequency) error {
+	if f == t.frequency {
+		return nil
+	}
+	if err := t.chip.setFrequency(f); err != nil {
+		return err
+	}
+	t.frequency = f
+	return nil
+}
+
+// Switch the module to receive mode, the demodulated signal is output on GDO0
+// (CC1101) or DIO2 (RFM69). Receiving is resumed after every transmission.
+func (t *Transmitter) Receive() error {
+	t.Lock()
+	defer t.Unlock()
+	t.receiving = true
+	return t.chip.receive()
+}
+
+// Close puts the module to sleep.
+func (t *Transmitter) Close() error {
+	t.Lock()
+	defer t.Unlock()
+	t.receiving = false
+	return t.chip.sleep()
+}
+
+// Returns the pulse train of tr with one bit per pulse length, most significant
+// bit first. It ends with a zero byte, so the carrier is off when the FIFO runs empty.
+func bits(tr rcswitch.Transmission) []byte {
+	var data []byte
+	var n uint
+	add := func(high bool, pulses int) {
+		for i := 0; i < pulses; i++ {
+			if n%8 == 0 {
+				data = append(data, 0)
+			}
+			if high {
+				data[len(data)-1] |= 0x80 >> (n % 8)
+			}
+			n++
+		}
+	}
+	pulses := func(high bool, n int) {
+		add(high != tr.Inverted, n)
+	}
+
+	for i := 0; i < tr.Preamble; i++ {
+		pulses(true, 1)
+		pulses(false, 1)
+	}
+	gap := int((tr.Gap + tr.PulseLength/2) / tr.PulseLength)
+	for r := 0; r < tr.Repeat; r++ {
+		if r > 0 {
+			add(false, gap) // carrier off, also after inverted frames
+		}
+		for _, w := range tr.Frame {
+			pulses(true, w.High)
+			pulses(false, w.Low)
+		}
+	}
+	return append(data, 0)
+}
+
+// Write data to a FIFO of size bytes, refilling it whenever level reports less
+// than half of it is in use, then wait until it ran empty.
+func fill(ctx context.Context, data []byte, size int, level func() (int, error), write func([]byte) error) error {
+	for {
+		used, err := level()
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 && used == 0 {
+			return nil
+		}
+		if n := size - used; len(data) > 0 && n >= size/2 {
+			if n > len(data) {
+				n = len(data)
+			}
+			if err := write(data[:n]); err != nil {
+				return err
+			}
+			data = data[n:]
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+var errFrequency = errors.New("Frequency has to be within 300 and 1000 MHz")
+
+func validFrequency(f physic.Frequency) bool {
+	return f >= 300*physic.MegaHertz && f <= 1000*physic.MegaHertz
+}
+
+// Read register addr.
+func read(conn spi.Conn, addr byte) (byte, error) {
+	r := make([]byte, 2)
+	if err := conn.Tx([]byte{addr, 0}, r); err != nil {
+		return 0, err
+	}
+	return r[1], nil
+}
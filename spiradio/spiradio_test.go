@@ -0,0 +1,77 @@
+package spiradio
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+
+	"github.com/rck/rcswitch"
+)
+
+func TestBits(t *testing.T) {
+	const p = 100 * time.Microsecond
+	frame := []rcswitch.Waveform{{High: 1, Low: 3}, {High: 3, Low: 1}}
+	tests := []struct {
+		name string
+		tr   rcswitch.Transmission
+		want []byte
+	}{
+		{"normal", rcswitch.Transmission{Frame: frame, PulseLength: p, Repeat: 1},
+			[]byte{0x8e, 0}},
+		{"inverted", rcswitch.Transmission{Frame: frame, PulseLength: p, Inverted: true, Repeat: 1},
+			[]byte{0x71, 0}},
+		{"preamble", rcswitch.Transmission{Frame: frame[:1], PulseLength: p, Preamble: 2, Repeat: 1},
+			[]byte{0xa8, 0}},
+		{"gap", rcswitch.Transmission{Frame: frame[:1], PulseLength: p, Repeat: 2, Gap: 4 * p},
+			[]byte{0x80, 0x80, 0}},
+		// The gap is silence, it must not be inverted.
+		{"inverted gap", rcswitch.Transmission{Frame: frame[:1], PulseLength: p, Inverted: true, Repeat: 2, Gap: 4 * p},
+			[]byte{0x70, 0x70, 0}},
+	}
+	for _, tt := range tests {
+		if got := bits(tt.tr); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: bits() = %x, expected %x", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Chip that records the frequencies it was tuned to.
+type fakeChip struct{ tuned []physic.Frequency }
+
+func (c *fakeChip) init(f physic.Frequency) error { return nil }
+func (c *fakeChip) setFrequency(f physic.Frequency) error {
+	c.tuned = append(c.tuned, f)
+	return nil
+}
+func (c *fakeChip) setBitTime(time.Duration) error     { return nil }
+func (c *fakeChip) send(context.Context, []byte) error { return nil }
+func (c *fakeChip) receive() error                     { return nil }
+func (c *fakeChip) standby() error                     { return nil }
+func (c *fakeChip) sleep() error                       { return nil }
+
+func TestTransmitFrequency(t *testing.T) {
+	const base = 433920 * physic.KiloHertz
+	c := &fakeChip{}
+	tx, err := newTransmitter(c, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Receive(); err != nil {
+		t.Fatal(err)
+	}
+	tr := rcswitch.Transmission{Frame: []rcswitch.Waveform{{High: 1, Low: 3}}, PulseLength: 350 * time.Microsecond, Repeat: 1}
+	for _, f := range []physic.Frequency{0, 315 * physic.MegaHertz, base} {
+		tr.Frequency = f
+		if err := tx.Transmit(context.Background(), tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only the 315MHz transmission retunes, and back for receiving
+	want := []physic.Frequency{315 * physic.MegaHertz, base}
+	if len(c.tuned) != len(want) || c.tuned[0] != want[0] || c.tuned[1] != want[1] {
+		t.Errorf("Tuned to %v, expected %v", c.tuned, want)
+	}
+}
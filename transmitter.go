@@ -0,0 +1,148 @@
+package rcswitch
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"periph.io/x/periph/conn/gpio"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Transmission is a pulse train to be sent by a Transmitter.
+type Transmission struct {
+	Frame       []Waveform // a single frame, including its sync bit
+	PulseLength time.Duration
+	Inverted    bool          // high and low are swapped
+	Preamble    int           // number of 1:1 pulses sent once before the first frame
+	Repeat      int           // number of times Frame is sent
+	Gap         time.Duration // additional low period between two frames
+	// Carrier frequency, 0 is the one the backend was set up with. Backends
+	// with a fixed frequency ignore it.
+	Frequency physic.Frequency
+}
+
+// Transmitter is the backend that puts transmissions on the air.
+// Besides the default GPIO backend (see NewGPIOTransmitter), backends can be
+// plugged in with SetTransmitter, e.g., for hardware timed output, serial
+// transmitters, or fakes in tests. Transmit is never called concurrently by
+// an RCSwitch object. If ctx is done, Transmit should stop as soon as possible
+// and return ctx.Err(). After a transmission the transmitter must not be keyed
+// up. If the backend implements io.Closer, it is closed by RCSwitch.Close.
+type Transmitter interface {
+	Transmit(ctx context.Context, t Transmission) error
+}
+
+// TimingMode selects how the GPIO transmitter waits between level changes.
+type TimingMode int
+
+const (
+	// Wait with time.Sleep. This is the default and works well for the
+	// common protocols with pulse lengths of 350µs and more on idle systems.
+	Sleep TimingMode = iota
+	// Sleep most of the time and busy-wait the rest. This costs a full CPU
+	// core while transmitting, but short-pulse protocols like protocol 3
+	// (100µs) only work this way, as time.Sleep overshoots by 100µs and more.
+	Precise
+)
+
+// Bounds of the time before a deadline at which Precise stops sleeping and
+// starts busy-waiting. Within them, it is calibrated to the sleep overshoot of
+// the system, see CalibrateWaitUntil.
+const (
+	minSpin = 50 * time.Microsecond
+	maxSpin = 2 * time.Millisecond
+)
+
+// Number of sleeps CalibrateWaitUntil measures, and how long each is.
+const (
+	calibrationSamples = 20
+	calibrationSleep   = 100 * time.Microsecond
+)
+
+var (
+	spinThreshold = int64(maxSpin) // nanoseconds, accessed atomically
+	calibration   sync.Once
+)
+
+// Measure how much time.Sleep overshoots on this system, so WaitUntil sleeps
+// as long as possible and busy-waits only for the rest. It takes a few
+// milliseconds, only the first call measures. WaitUntil calibrates on its
+// first call, backends that use it should call this before their first
+// transmission, so that the first pulse is not stretched.
+// SetTimingMode(Precise) calls it.
+func CalibrateWaitUntil() {
+	calibration.Do(func() {
+		overshoots := make([]time.Duration, calibrationSamples)
+		for i := range overshoots {
+			start := time.Now()
+			time.Sleep(calibrationSleep)
+			overshoots[i] = time.Since(start) - calibrationSleep
+		}
+		// the 90th percentile with a margin, single preemptions are rare
+		// enough to not spin for them on every edge
+		sort.Slice(overshoots, func(i, j int) bool { return overshoots[i] < overshoots[j] })
+		spin := overshoots[len(overshoots)*9/10] * 3 / 2
+		if spin < minSpin {
+			spin = minSpin
+		} else if spin > maxSpin {
+			spin = maxSpin
+		}
+		atomic.StoreInt64(&spinThreshold, int64(spin))
+	})
+}
+
+// Wait until t: sleep most of the time, then busy-wait for as long as sleeping
+// overshoots, see CalibrateWaitUntil. Waits shorter than that, e.g., the 100µs
+// pulses of protocol 3 on most systems, are busy-waited entirely. This is how
+// the Precise timing mode waits, backends of other packages with the same
+// needs can use it, too.
+func WaitUntil(t time.Time) {
+	CalibrateWaitUntil()
+	if d := time.Until(t) - time.Duration(atomic.LoadInt64(&spinThreshold)); d > 0 {
+		time.Sleep(d)
+	}
+	for time.Now().Before(t) {
+	}
+}
+
+type gpioTransmitter struct {
+	pin       gpio.PinIO
+	mode      TimingMode
+	activeLow bool
+
+	// Statistics of the last transmission, see TransmissionReport.
+	frames       int
+	maxOvershoot time.Duration
+}
+
+// Returns the level of the pin while not transmitting.
+func (t *gpioTransmitter) idle() gpio.Level {
+	if t.activeLow {
+		return gpio.High
+	}
+	return gpio.Low
+}
+
+// Create a Transmitter that bit-bangs the given pin, timed by time.Sleep.
+// This is the default backend, see SetPin.
+func NewGPIOTransmitter(pin gpio.PinIO) Transmitter {
+	return &gpioTransmitter{pin: pin}
+}
+
+func newTransmission(ws []waveform, prot protocol, nrRepeat int) Transmission {
+	frame := make([]Waveform, len(ws))
+	for i, w := range ws {
+		frame[i] = exportWaveform(w)
+	}
+	return Transmission{
+		Frame:       frame,
+		PulseLength: prot.pulseLen * time.Microsecond,
+		Inverted:    prot.inverted,
+		Preamble:    prot.preamble,
+		Repeat:      nrRepeat,
+		Frequency:   prot.frequency,
+	}
+}
@@ -0,0 +1,152 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// ErrNotVerified is returned if a transmission was not heard by the receiver
+// set by SetVerification, not even after all retries. The error actually
+// returned is a *NotVerifiedError, use errors.Is to check for it.
+var ErrNotVerified = errors.New("Transmission was not heard by the receiver")
+
+// NotVerifiedError is returned if a transmission was not heard by the
+// receiver set by SetVerification. It carries how often it was transmitted.
+type NotVerifiedError struct {
+	Attempts int
+}
+
+func (e *NotVerifiedError) Error() string {
+	return fmt.Sprintf("Transmission was not heard by the receiver after %d attempts", e.Attempts)
+}
+
+// Is makes errors.Is(err, ErrNotVerified) work.
+func (e *NotVerifiedError) Is(target error) bool {
+	return target == ErrNotVerified
+}
+
+// How long to wait for the receiver to report a transmission after it ended.
+const verifyTimeout = 250 * time.Millisecond
+
+// RetryPolicy sets how transmissions that were not heard by the verification
+// receiver are retransmitted, see SetRetryPolicy.
+type RetryPolicy struct {
+	Attempts int           // transmissions in total, at least 1
+	Backoff  time.Duration // wait before the first retransmission, doubled for every further one
+	Jitter   time.Duration // random deviation of each wait, so two transmitters do not collide again
+}
+
+type verification struct {
+	rx     *Receiver
+	policy RetryPolicy
+}
+
+// Verify transmissions with a receiver module next to the transmitter: after
+// sending a code word, wait for rx to decode it and retransmit up to retries
+// times if it was not heard. If it was not heard at all, a *NotVerifiedError
+// is returned. This only proves that the code was on the air, not that a socket
+// reacted to it. Codes received by rx are still delivered by its Codes. A nil
+// rx disables verification, which is the default. Pair, StartContinuous,
+// SendRaw, and the KaKu commands are not verified.
+// Retransmissions follow right away, see SetRetryPolicy to wait in between.
+func (s *RCSwitch) SetVerification(rx *Receiver, retries int) error {
+	if retries < 0 {
+		return errors.New("Number of retries must not be negative")
+	}
+	s.Lock()
+	s.verify = verification{rx: rx, policy: RetryPolicy{Attempts: retries + 1}}
+	s.Unlock()
+	return nil
+}
+
+// Set how transmissions are retransmitted if the receiver of SetVerification
+// did not hear them. This replaces the retries of SetVerification. Waiting
+// with exponential backoff gives a transmitter next door, or whatever else
+// jammed the band, the time to finish. The jitter has to be smaller than the
+// backoff.
+func (s *RCSwitch) SetRetryPolicy(p RetryPolicy) error {
+	if p.Attempts < 1 {
+		return errors.New("Number of attempts has to be at least 1")
+	}
+	if p.Backoff < 0 || p.Jitter < 0 || (p.Jitter > 0 && p.Jitter >= p.Backoff) {
+		return errors.New("Backoff has to be positive and jitter within 0 and backoff")
+	}
+	s.Lock()
+	s.verify.policy = p
+	s.Unlock()
+	return nil
+}
+
+// Transmit ws, the waveforms of binary, and retransmit until it is heard by
+// the verification receiver. Has to be called with s locked.
+func (s *RCSwitch) transmitVerified(ctx context.Context, binary string, ws []waveform, prot protocol, nrRepeat int) error {
+	want, err := strconv.ParseUint(binary, 2, 64)
+	if s.verify.rx == nil || s.dryRun || err != nil { // nothing on the air, or longer than the receiver can decode
+		return s.transmit(ctx, ws, prot, nrRepeat)
+	}
+
+	p := s.verify.policy
+	codes, cancel := s.verify.rx.listen()
+	defer cancel()
+	for attempt := 1; ; attempt++ {
+		if err := s.transmit(ctx, ws, prot, nrRepeat); err != nil {
+			return err
+		}
+		if heard(ctx, codes, want, len(binary)) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if attempt >= p.Attempts {
+			return &NotVerifiedError{Attempts: attempt}
+		}
+		if err := backoff(ctx, p, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// Wait before retransmission number attempt.
+func backoff(ctx context.Context, p RetryPolicy, attempt int) error {
+	if attempt > 16 { // keep it from overflowing
+		attempt = 16
+	}
+	d := p.Backoff << uint(attempt-1)
+	if p.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(2*p.Jitter))) - p.Jitter
+	}
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// Wait until the code was received, or verifyTimeout passed.
+func heard(ctx context.Context, codes <-chan ReceivedCode, value uint64, bitLength int) bool {
+	t := time.NewTimer(verifyTimeout)
+	defer t.Stop()
+	for {
+		select {
+		case c := <-codes:
+			if c.Value == value && c.BitLength == bitLength {
+				return true
+			}
+		case <-t.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
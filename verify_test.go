@@ -0,0 +1,65 @@
+package rcswitch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Transmitter that is heard by rx from the given attempt on, 0 means never.
+type echoTransmitter struct {
+	rx       *Receiver
+	heardAt  int
+	attempts *int
+}
+
+func (e echoTransmitter) Transmit(_ context.Context, _ Transmission) error {
+	*e.attempts++
+	if e.heardAt > 0 && *e.attempts >= e.heardAt {
+		// type B 1 1 on, 0FFF0FFFFFFF
+		e.rx.deliver(ReceivedCode{Value: 0x151555, BitLength: 24, Protocol: 1, PulseLength: 350 * time.Microsecond})
+	}
+	return nil
+}
+
+func TestRetryPolicy(t *testing.T) {
+	for _, test := range []struct {
+		heardAt, attempts int
+		notVerified       bool
+	}{
+		{1, 1, false},
+		{2, 2, false},
+		{0, 3, true},
+	} {
+		rx := &Receiver{codes: make(chan ReceivedCode, 16)}
+		var attempts int
+		s := NewRCSwitch(nil)
+		s.SetTransmitter(echoTransmitter{rx, test.heardAt, &attempts})
+		if err := s.SetVerification(rx, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetRetryPolicy(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}); err != nil {
+			t.Fatal(err)
+		}
+
+		err := s.SwitchOn("", "1", "1")
+		if attempts != test.attempts {
+			t.Errorf("Heard at %d: transmitted %d times, expected %d", test.heardAt, attempts, test.attempts)
+		}
+		var nv *NotVerifiedError
+		switch {
+		case !test.notVerified && err != nil:
+			t.Errorf("Heard at %d: %v", test.heardAt, err)
+		case test.notVerified && (!errors.As(err, &nv) || nv.Attempts != 3 || !errors.Is(err, ErrNotVerified)):
+			t.Errorf("Heard at %d: got %v, expected not verified after 3 attempts", test.heardAt, err)
+		}
+	}
+
+	s := NewRCSwitch(nil)
+	for _, p := range []RetryPolicy{{}, {Attempts: 2, Backoff: -time.Second}, {Attempts: 2, Backoff: time.Second, Jitter: time.Second}} {
+		if err := s.SetRetryPolicy(p); err == nil {
+			t.Errorf("Policy %+v was accepted", p)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package rcswitch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CodeWriter transmits every line written to it as a code.
+// A line is either a tri-state code word of 0, 1, and F (e.g., "0FFF0FFFFF0F"),
+// or a decimal code followed by a slash and its length in bits (e.g.,
+// "5393/24"), which is sent like Send does. The length is required, as
+// decimal codes like "10" would otherwise be tri-state code words.
+// Empty lines are ignored.
+// This makes it easy to pipe codes from other programs.
+type CodeWriter struct {
+	s   *RCSwitch
+	buf []byte
+}
+
+// Create a CodeWriter transmitting with the given RCSwitch object.
+func NewCodeWriter(s *RCSwitch) *CodeWriter {
+	return &CodeWriter{s: s}
+}
+
+// Write transmits every complete line in p, a trailing partial line is kept
+// until it is completed by the next Write or sent by Close.
+// Lines are transmitted synchronously, Write returns when all of them are sent.
+func (w *CodeWriter) Write(p []byte) (int, error) {
+	n := 0
+	for {
+		i := bytes.IndexByte(p[n:], '\n')
+		if i < 0 {
+			break
+		}
+		line := append(w.buf, p[n:n+i]...)
+		w.buf = w.buf[:0]
+		n += i + 1
+		if err := w.s.sendLine(string(line)); err != nil {
+			return n, err
+		}
+	}
+	w.buf = append(w.buf, p[n:]...)
+	return len(p), nil
+}
+
+// Close transmits a trailing line that was not terminated by a newline.
+func (w *CodeWriter) Close() error {
+	line := string(w.buf)
+	w.buf = nil
+	return w.s.sendLine(line)
+}
+
+func (s *RCSwitch) sendLine(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+
+	if i := strings.IndexByte(line, '/'); i >= 0 {
+		code, err := strconv.ParseUint(line[:i], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Code of line %q is not a decimal number", line)
+		}
+		bitLength, err := strconv.Atoi(line[i+1:])
+		if err != nil {
+			return fmt.Errorf("Bit length of line %q is not a number", line)
+		}
+		return s.Send(code, bitLength)
+	}
+	if strings.Trim(line, "01F") != "" {
+		return fmt.Errorf("Line %q is neither a tri-state code word nor a decimal code with its bit length, e.g., 5393/24", line)
+	}
+	return s.SendTriState(line)
+}
+
+// Convert code to a binary string of length bitLength, most significant bit
+// first unless lsbFirst is set.
+func decimalToBinary(code uint64, bitLength int, lsbFirst bool) (string, error) {
+	if bitLength <= 0 || bitLength > 64 {
+		return "", errors.New("Bit length has to be within the range of 1 to 64")
+	}
+	if code>>uint(bitLength) != 0 {
+		return "", fmt.Errorf("Code %d does not fit into %d bits", code, bitLength)
+	}
+	binary := fmt.Sprintf("%0*b", bitLength, code)
+	if lsbFirst {
+		b := []byte(binary)
+		for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+			b[i], b[j] = b[j], b[i]
+		}
+		binary = string(b)
+	}
+	return binary, nil
+}
@@ -0,0 +1,154 @@
+package rcswitch
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecimalToBinary(t *testing.T) {
+	tests := []struct {
+		code      uint64
+		bitLength int
+		lsbFirst  bool
+		want      string // empty if an error is expected
+	}{
+		{5393, 24, false, "000000000001010100010001"},
+		{5393, 24, true, "100010001010100000000000"},
+		{1 << 32, 33, false, "1" + strings.Repeat("0", 32)},
+		{1, 33, false, strings.Repeat("0", 32) + "1"},
+		{0xabcdef012, 36, false, "101010111100110111101111000000010010"},
+		{0xabcdef012, 36, true, "010010000000111101111011001111010101"},
+		{1<<47 | 1, 48, false, "1" + strings.Repeat("0", 46) + "1"},
+		{1<<63 | 1, 64, false, "1" + strings.Repeat("0", 62) + "1"},
+		{^uint64(0), 64, false, strings.Repeat("1", 64)},
+		{^uint64(0), 64, true, strings.Repeat("1", 64)},
+		{0, 64, false, strings.Repeat("0", 64)},
+		{1 << 33, 33, false, ""},
+		{1 << 36, 36, false, ""},
+		{^uint64(0), 63, false, ""},
+		{1 << 40, 40, false, ""},
+		{1, 65, false, ""},
+		{1, 0, false, ""},
+		{1, -1, false, ""},
+	}
+	for _, tt := range tests {
+		got, err := decimalToBinary(tt.code, tt.bitLength, tt.lsbFirst)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("decimalToBinary(%d, %d) = %q, expected an error", tt.code, tt.bitLength, got)
+		case tt.want != "" && err != nil:
+			t.Errorf("decimalToBinary(%d, %d) failed: %v", tt.code, tt.bitLength, err)
+		case got != tt.want:
+			t.Errorf("decimalToBinary(%d, %d, %t) = %q, expected %q", tt.code, tt.bitLength, tt.lsbFirst, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalToBinaryAllLengths(t *testing.T) {
+	for bitLength := 33; bitLength <= 64; bitLength++ {
+		max := ^uint64(0) >> uint(64-bitLength)
+		got, err := decimalToBinary(max, bitLength, false)
+		if err != nil || got != strings.Repeat("1", bitLength) {
+			t.Errorf("decimalToBinary(%d, %d) = %q, %v", max, bitLength, got, err)
+		}
+		got, err = decimalToBinary(1<<uint(bitLength-1), bitLength, false)
+		if err != nil || got != "1"+strings.Repeat("0", bitLength-1) {
+			t.Errorf("decimalToBinary(MSB, %d) = %q, %v", bitLength, got, err)
+		}
+		if bitLength < 64 {
+			if _, err := decimalToBinary(max+1, bitLength, false); err == nil {
+				t.Errorf("decimalToBinary(%d, %d) does not fail", max+1, bitLength)
+			}
+		}
+	}
+}
+
+func TestSendLongCodes(t *testing.T) {
+	tests := []struct {
+		code      uint64
+		bitLength int
+		binary    string
+	}{
+		{0x1deadbeef, 33, "111011110101011011011111011101111"},
+		{0xabcdef012, 36, "101010111100110111101111000000010010"},
+		{^uint64(0), 64, strings.Repeat("1", 64)},
+	}
+	prot := Protocols()[0]
+	for _, tt := range tests {
+		var got Transmission
+		s := NewRCSwitch(nil)
+		s.SetTransmitter(lastTransmitter{&got})
+		s.SetRepeat(2)
+		if err := s.Send(tt.code, tt.bitLength); err != nil {
+			t.Fatalf("Send(%d, %d) failed: %v", tt.code, tt.bitLength, err)
+		}
+
+		var want []Waveform
+		for _, b := range tt.binary {
+			if b == '1' {
+				want = append(want, prot.One)
+			} else {
+				want = append(want, prot.Zero)
+			}
+		}
+		want = append(want, prot.Sync)
+		if !reflect.DeepEqual(got.Frame, want) {
+			t.Errorf("Send(%d, %d) sent %v, expected %v", tt.code, tt.bitLength, got.Frame, want)
+		}
+		if got.Repeat != 2 {
+			t.Errorf("Send(%d, %d) sent %d frames, expected 2", tt.code, tt.bitLength, got.Repeat)
+		}
+	}
+}
+
+// Transmitter that keeps the last transmission.
+type lastTransmitter struct{ tr *Transmission }
+
+func (l lastTransmitter) Transmit(_ context.Context, tr Transmission) error {
+	*l.tr = tr
+	return nil
+}
+
+func TestSendLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		binary string // empty if an error is expected
+	}{
+		{"10", "1100"},
+		{"1101", "11110011"},
+		{"0FFF0FFFFF0F", "000101010001010101010001"},
+		{"10/4", "1010"},
+		{"13/4", "1101"},
+		{"1101/4", ""},
+		{"5393/24", "000000000001010100010001"},
+		{" 5393/24 ", "000000000001010100010001"},
+		{"5393", ""},
+		{"5393 24", ""},
+		{"10/", ""},
+		{"/24", ""},
+		{"5393/x", ""},
+		{"5393/8", ""},
+	}
+	for _, tt := range tests {
+		var got Transmission
+		s := NewRCSwitch(nil)
+		s.SetTransmitter(lastTransmitter{&got})
+		err := s.sendLine(tt.line)
+		if tt.binary == "" {
+			if err == nil {
+				t.Errorf("sendLine(%q) succeeded, expected an error", tt.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("sendLine(%q) failed: %v", tt.line, err)
+			continue
+		}
+		want, _ := s.Transmission(tt.binary)
+		if !reflect.DeepEqual(got.Frame, want.Frame) {
+			t.Errorf("sendLine(%q) sent %v, expected %v", tt.line, got.Frame, want.Frame)
+		}
+	}
+}